@@ -1,13 +1,13 @@
 package apn
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"iter"
 	"math/bits"
-	"net/url"
 	"slices"
 	"strconv"
+	"strings"
 )
 
 // https://cs.android.com/android/platform/superproject/main/+/main:frameworks/base/telephony/java/android/telephony/data/ApnSetting.java;drc=4ba139804a0a420c376d8fffbcb7e9f2fa3f65a8
@@ -120,6 +120,7 @@ type AuthType int
 
 const (
 	AUTH_TYPE_UNKNOWN     AuthType = iota - 1 // unknown
+	AUTH_TYPE_NONE                            // none
 	AUTH_TYPE_PAP                             // PAP
 	AUTH_TYPE_CHAP                            // CHAP
 	AUTH_TYPE_PAP_OR_CHAP                     // PAP or CHAP
@@ -213,6 +214,31 @@ func (x NetworkType) String() string {
 	}
 }
 
+func (x NetworkType) MarshalText() ([]byte, error) {
+	if !x.Valid() {
+		return nil, fmt.Errorf("unknown network type %d", x)
+	}
+	return []byte(x.String()), nil
+}
+
+// UnmarshalText parses a network type name (e.g., LTE) or number (e.g., 13).
+func (x *NetworkType) UnmarshalText(b []byte) error {
+	if v, err := strconv.ParseInt(string(b), 10, 0); err == nil {
+		if !NetworkType(v).Valid() {
+			return fmt.Errorf("unknown network type %q", string(b))
+		}
+		*x = NetworkType(v)
+		return nil
+	}
+	for t := NETWORK_TYPE_GPRS; t <= NETWORK_TYPE_NR; t++ {
+		if strings.EqualFold(t.String(), string(b)) {
+			*x = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown network type %q", string(b))
+}
+
 func MakeNetworkTypeBitmask(t ...NetworkType) NetworkTypeBitmask {
 	return MakeNetworkTypeBitmaskSeq(slices.Values(t))
 }
@@ -236,6 +262,7 @@ func (x NetworkTypeBitmask) String() string {
 
 func (x NetworkTypeBitmask) Seq() iter.Seq[NetworkType] {
 	return func(yield func(NetworkType) bool) {
+		x := x
 		for i := 0; i < bits.UintSize; i++ {
 			if x&1 != 0 {
 				if !yield(NetworkType(i + 1)) {
@@ -249,23 +276,22 @@ func (x NetworkTypeBitmask) Seq() iter.Seq[NetworkType] {
 	}
 }
 
+// UnmarshalText parses a list of network types separated by '|' (the format
+// used by apns-conf.xml). See NetworkType.UnmarshalText. Like
+// ServiceState.getBitmaskFromString, "0" is the empty bitmask.
 func (x *NetworkTypeBitmask) UnmarshalText(b []byte) error {
-	var err error
-	*x = MakeNetworkTypeBitmaskSeq(func(yield func(NetworkType) bool) {
-		if len(b) != 0 {
-			for _, t := range bytes.Split(b, []byte{'|'}) {
-				v, err1 := strconv.ParseInt(string(t), 10, 0)
-				if err1 != nil || NetworkType(v).String() == "" {
-					err = fmt.Errorf("invalid network type %q", string(t))
-					return
-				}
-				if !yield(NetworkType(v)) {
-					return
-				}
+	var v NetworkTypeBitmask
+	if s := strings.TrimSpace(string(b)); s != "" && s != "0" {
+		for _, s := range strings.Split(s, "|") {
+			var t NetworkType
+			if err := t.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+				return err
 			}
+			v |= MakeNetworkTypeBitmask(t)
 		}
-	})
-	return err
+	}
+	*x = v
+	return nil
 }
 
 func (x NetworkTypeBitmask) MarshalText() ([]byte, error) {
@@ -320,52 +346,54 @@ func (x Type) Seq() iter.Seq[Type] {
 	}
 }
 
+// UnmarshalText parses a list of types separated by ',' (like
+// ApnSetting.getApnTypesBitmaskFromString) or '|'. Whitespace is ignored, and
+// types are case-insensitive. The empty string is TYPE_NONE.
 func (x *Type) UnmarshalText(b []byte) error {
-	*x = TYPE_NONE
-	if len(b) != 0 {
-		if string(b) == TYPE_ALL_STRING {
-			*x = TYPE_ALL
-			return nil
-		}
-		for _, t := range bytes.Split(b, []byte{'|'}) {
-			switch t := string(t); t {
-			case TYPE_DEFAULT_STRING:
-				*x |= TYPE_DEFAULT
-			case TYPE_MMS_STRING:
-				*x |= TYPE_MMS
-			case TYPE_SUPL_STRING:
-				*x |= TYPE_SUPL
-			case TYPE_DUN_STRING:
-				*x |= TYPE_DUN
-			case TYPE_HIPRI_STRING:
-				*x |= TYPE_HIPRI
-			case TYPE_FOTA_STRING:
-				*x |= TYPE_FOTA
-			case TYPE_IMS_STRING:
-				*x |= TYPE_IMS
-			case TYPE_CBS_STRING:
-				*x |= TYPE_CBS
-			case TYPE_IA_STRING:
-				*x |= TYPE_IA
-			case TYPE_EMERGENCY_STRING:
-				*x |= TYPE_EMERGENCY
-			case TYPE_MCX_STRING:
-				*x |= TYPE_MCX
-			case TYPE_XCAP_STRING:
-				*x |= TYPE_XCAP
-			case TYPE_VSIM_STRING:
-				*x |= TYPE_VSIM
-			case TYPE_BIP_STRING:
-				*x |= TYPE_BIP
-			case TYPE_ENTERPRISE_STRING:
-				*x |= TYPE_ENTERPRISE
-			case TYPE_RCS_STRING:
-				*x |= TYPE_RCS
-			default:
-				return fmt.Errorf("unknown type %q", t)
-			}
+	var v Type
+	for _, t := range strings.FieldsFunc(strings.Join(strings.Fields(string(b)), ""), func(r rune) bool {
+		return r == ',' || r == '|'
+	}) {
+		switch t := strings.ToLower(t); t {
+		case TYPE_ALL_STRING:
+			v |= TYPE_ALL
+		case TYPE_DEFAULT_STRING:
+			v |= TYPE_DEFAULT
+		case TYPE_MMS_STRING:
+			v |= TYPE_MMS
+		case TYPE_SUPL_STRING:
+			v |= TYPE_SUPL
+		case TYPE_DUN_STRING:
+			v |= TYPE_DUN
+		case TYPE_HIPRI_STRING:
+			v |= TYPE_HIPRI
+		case TYPE_FOTA_STRING:
+			v |= TYPE_FOTA
+		case TYPE_IMS_STRING:
+			v |= TYPE_IMS
+		case TYPE_CBS_STRING:
+			v |= TYPE_CBS
+		case TYPE_IA_STRING:
+			v |= TYPE_IA
+		case TYPE_EMERGENCY_STRING:
+			v |= TYPE_EMERGENCY
+		case TYPE_MCX_STRING:
+			v |= TYPE_MCX
+		case TYPE_XCAP_STRING:
+			v |= TYPE_XCAP
+		case TYPE_VSIM_STRING:
+			v |= TYPE_VSIM
+		case TYPE_BIP_STRING:
+			v |= TYPE_BIP
+		case TYPE_ENTERPRISE_STRING:
+			v |= TYPE_ENTERPRISE
+		case TYPE_RCS_STRING:
+			v |= TYPE_RCS
+		default:
+			return fmt.Errorf("unknown type %q", t)
 		}
 	}
+	*x = v
 	return nil
 }
 
@@ -449,25 +477,106 @@ func (x Protocol) MarshalText() ([]byte, error) {
 	}
 }
 
-func (p *Protocol) UnmarshalText(t []byte) (Protocol, error) {
-	switch t := string(t); t {
+// UnmarshalText parses a protocol (case-insensitive).
+func (x *Protocol) UnmarshalText(t []byte) error {
+	switch t := strings.ToUpper(string(t)); t {
 	case "IP":
-		return PROTOCOL_IP, nil
+		*x = PROTOCOL_IP
 	case "IPV6":
-		return PROTOCOL_IPV6, nil
+		*x = PROTOCOL_IPV6
 	case "IPV4V6":
-		return PROTOCOL_IPV4V6, nil
+		*x = PROTOCOL_IPV4V6
 	case "PPP":
-		return PROTOCOL_PPP, nil
+		*x = PROTOCOL_PPP
 	case "NON-IP":
-		return PROTOCOL_NON_IP, nil
+		*x = PROTOCOL_NON_IP
 	case "UNSTRUCTURED":
-		return PROTOCOL_UNSTRUCTURED, nil
+		*x = PROTOCOL_UNSTRUCTURED
+	default:
+		return fmt.Errorf("unknown protocol %q", t)
+	}
+	return nil
+}
+
+func (x AuthType) Valid() bool {
+	return x.String() != ""
+}
+
+func (x AuthType) String() string {
+	b, _ := x.MarshalText()
+	return string(b)
+}
+
+func (x AuthType) MarshalText() ([]byte, error) {
+	switch x {
+	case AUTH_TYPE_NONE:
+		return []byte("NONE"), nil
+	case AUTH_TYPE_PAP:
+		return []byte("PAP"), nil
+	case AUTH_TYPE_CHAP:
+		return []byte("CHAP"), nil
+	case AUTH_TYPE_PAP_OR_CHAP:
+		return []byte("PAP_OR_CHAP"), nil
+	default:
+		return nil, fmt.Errorf("unknown auth type %#v", x)
+	}
+}
+
+// UnmarshalText parses an auth type name or number (as used in apns-conf.xml).
+func (x *AuthType) UnmarshalText(t []byte) error {
+	switch t := strings.ToUpper(string(t)); t {
+	case "NONE", "0":
+		*x = AUTH_TYPE_NONE
+	case "PAP", "1":
+		*x = AUTH_TYPE_PAP
+	case "CHAP", "2":
+		*x = AUTH_TYPE_CHAP
+	case "PAP_OR_CHAP", "3":
+		*x = AUTH_TYPE_PAP_OR_CHAP
+	default:
+		return fmt.Errorf("unknown auth type %q", t)
+	}
+	return nil
+}
+
+func (x Skip464XLAT) Valid() bool {
+	return x.String() != ""
+}
+
+func (x Skip464XLAT) String() string {
+	b, _ := x.MarshalText()
+	return string(b)
+}
+
+func (x Skip464XLAT) MarshalText() ([]byte, error) {
+	switch x {
+	case SKIP_464XLAT_DEFAULT:
+		return []byte("DEFAULT"), nil
+	case SKIP_464XLAT_DISABLE:
+		return []byte("DISABLE"), nil
+	case SKIP_464XLAT_ENABLE:
+		return []byte("ENABLE"), nil
 	default:
-		return PROTOCOL_UNKNOWN, fmt.Errorf("unknown protocol %q", t)
+		return nil, fmt.Errorf("unknown skip 464xlat value %#v", x)
 	}
 }
 
+// UnmarshalText parses a skip 464xlat value name or number (as used in
+// apns-conf.xml).
+func (x *Skip464XLAT) UnmarshalText(t []byte) error {
+	switch t := strings.ToUpper(string(t)); t {
+	case "DEFAULT", "-1":
+		*x = SKIP_464XLAT_DEFAULT
+	case "DISABLE", "0":
+		*x = SKIP_464XLAT_DISABLE
+	case "ENABLE", "1":
+		*x = SKIP_464XLAT_ENABLE
+	default:
+		return fmt.Errorf("unknown skip 464xlat value %q", t)
+	}
+	return nil
+}
+
 func (x MVNOType) Valid() bool {
 	return x.String() != ""
 }
@@ -492,19 +601,22 @@ func (x MVNOType) MarshalText() ([]byte, error) {
 	}
 }
 
-func (x *MVNOType) UnmarshalText(t []byte) (MVNOType, error) {
-	switch t := string(t); t {
+// UnmarshalText parses a mvno type (case-insensitive, like
+// ApnSetting.getMvnoTypeIntFromString).
+func (x *MVNOType) UnmarshalText(t []byte) error {
+	switch t := strings.ToLower(string(t)); t {
 	case "spn":
-		return MVNO_TYPE_SPN, nil
+		*x = MVNO_TYPE_SPN
 	case "imsi":
-		return MVNO_TYPE_IMSI, nil
+		*x = MVNO_TYPE_IMSI
 	case "gid":
-		return MVNO_TYPE_GID, nil
+		*x = MVNO_TYPE_GID
 	case "iccid":
-		return MVNO_TYPE_ICCID, nil
+		*x = MVNO_TYPE_ICCID
 	default:
-		return MVNO_TYPE_UNKNOWN, fmt.Errorf("unknown mvno type %q", t)
+		return fmt.Errorf("unknown mvno type %q", t)
 	}
+	return nil
 }
 
 func (x Infrastructure) Valid() bool {
@@ -529,17 +641,22 @@ func (x Infrastructure) MarshalText() ([]byte, error) {
 	}
 }
 
-func (p *Infrastructure) UnmarshalText(t []byte) (Infrastructure, error) {
-	switch t := string(t); t {
-	case "cellular":
-		return INFRASTRUCTURE_CELLULAR, nil
-	case "satellite":
-		return INFRASTRUCTURE_SATELLITE, nil
-	case "cellular|satellite", "satellite|cellular":
-		return INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE, nil
-	default:
-		return INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE, fmt.Errorf("unknown infrastructure type %q", t)
+// UnmarshalText parses a list of infrastructure types separated by '|'
+// (case-insensitive, like TelephonyProvider.getInfrastructureBitmask).
+func (x *Infrastructure) UnmarshalText(t []byte) error {
+	var v Infrastructure
+	for _, s := range strings.Split(string(t), "|") {
+		switch s := strings.ToLower(strings.TrimSpace(s)); s {
+		case "cellular":
+			v |= INFRASTRUCTURE_CELLULAR
+		case "satellite":
+			v |= INFRASTRUCTURE_SATELLITE
+		default:
+			return fmt.Errorf("unknown infrastructure type %q", s)
+		}
 	}
+	*x = v
+	return nil
 }
 
 type Setting struct {
@@ -626,27 +743,15 @@ func Empty() Setting {
 	}
 }
 
-// Check checks certain APN fields (same as ApnSetting.build).
+// Check returns the error-severity findings from the default lint rules.
+//
+// Deprecated: Use Lint.
 func (s Setting) Check() error {
-	if s.EntryName == "" {
-		return fmt.Errorf("entry name is required")
-	}
-	if s.APNName == "" {
-		return fmt.Errorf("apn name is required")
-	}
-	if !s.APNTypeBitmask.Valid() {
-		return fmt.Errorf("invalid apn type bitmask")
-	}
-	if s.APNTypeBitmask&TYPE_MMS != 0 {
-		if u, err := url.Parse(s.MMSProxyAddress); err == nil && u.Scheme != "" {
-			return fmt.Errorf("mms proxy should be a hostname, not a url")
-		}
-	}
-	// extra: check that bearer bitmask is in sync with network type bitmask if set
-	if s.BearerBitmask != 0 {
-		if x, y := ConvertBearerBitmaskToNetworkTypeBitmask(s.BearerBitmask), s.NetworkTypeBitmask; x != y {
-			return fmt.Errorf("overridden bearer bitmask converted to a network bitmask (lossy) is %s, but the network type bitmask is set to %s", slices.Collect(x.Seq()), slices.Collect(y.Seq()))
+	var errs []error
+	for _, f := range Lint(s, nil) {
+		if f.Severity >= SeverityError {
+			errs = append(errs, errors.New(f.Message))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,304 @@
+package apn
+
+import (
+	"encoding"
+	"testing"
+)
+
+type textValue[T any] interface {
+	*T
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
+
+type textCase[T any] struct {
+	In   string
+	Want T
+	Out  string // canonical form
+}
+
+// testText checks that each case unmarshals to the expected value, marshals to
+// the canonical form, and that the canonical form unmarshals to the same value.
+// It also checks that the rejected strings fail without changing the value.
+func testText[T comparable, P textValue[T]](t *testing.T, cases []textCase[T], reject []string) {
+	t.Helper()
+	for _, c := range cases {
+		var v T
+		if err := P(&v).UnmarshalText([]byte(c.In)); err != nil {
+			t.Errorf("unmarshal %q: unexpected error: %v", c.In, err)
+			continue
+		}
+		if v != c.Want {
+			t.Errorf("unmarshal %q: expected %v, got %v", c.In, c.Want, v)
+			continue
+		}
+		b, err := P(&v).MarshalText()
+		if err != nil {
+			t.Errorf("marshal %v (from %q): unexpected error: %v", v, c.In, err)
+			continue
+		}
+		if string(b) != c.Out {
+			t.Errorf("marshal %v (from %q): expected %q, got %q", v, c.In, c.Out, string(b))
+			continue
+		}
+		var r T
+		if err := P(&r).UnmarshalText(b); err != nil {
+			t.Errorf("unmarshal %q (from %q): unexpected error: %v", string(b), c.In, err)
+		} else if r != v {
+			t.Errorf("unmarshal %q (from %q): expected %v, got %v", string(b), c.In, v, r)
+		}
+	}
+	for _, s := range reject {
+		var v, z T
+		if err := P(&v).UnmarshalText([]byte(s)); err == nil {
+			t.Errorf("unmarshal %q: expected error, got %v", s, v)
+		} else if v != z {
+			t.Errorf("unmarshal %q: value changed on error to %v", s, v)
+		}
+	}
+}
+
+// testRoundTrip checks that each value survives Marshal→Unmarshal→Marshal.
+func testRoundTrip[T comparable, P textValue[T]](t *testing.T, vs ...T) {
+	t.Helper()
+	for _, v := range vs {
+		b1, err := P(&v).MarshalText()
+		if err != nil {
+			t.Errorf("marshal %v: unexpected error: %v", v, err)
+			continue
+		}
+		var r T
+		if err := P(&r).UnmarshalText(b1); err != nil {
+			t.Errorf("unmarshal %q: unexpected error: %v", string(b1), err)
+			continue
+		}
+		if r != v {
+			t.Errorf("unmarshal %q: expected %v, got %v", string(b1), v, r)
+			continue
+		}
+		if b2, err := P(&r).MarshalText(); err != nil {
+			t.Errorf("marshal %v: unexpected error: %v", r, err)
+		} else if string(b2) != string(b1) {
+			t.Errorf("marshal %v: expected %q, got %q", r, string(b1), string(b2))
+		}
+	}
+}
+
+// testMarshalError checks that each value fails to marshal.
+func testMarshalError[T any, P textValue[T]](t *testing.T, vs ...T) {
+	t.Helper()
+	for _, v := range vs {
+		if b, err := P(&v).MarshalText(); err == nil {
+			t.Errorf("marshal %v: expected error, got %q", v, string(b))
+		}
+	}
+}
+
+func TestTypeText(t *testing.T) {
+	testText[Type](t, []textCase[Type]{
+		{"", TYPE_NONE, ""},
+		{"default", TYPE_DEFAULT, "default"},
+		{"DEFAULT", TYPE_DEFAULT, "default"},
+		{"Default,MMS", TYPE_DEFAULT | TYPE_MMS, "default,mms"},
+		{"default|mms", TYPE_DEFAULT | TYPE_MMS, "default,mms"},
+		{"mms,default", TYPE_DEFAULT | TYPE_MMS, "default,mms"},
+		{"default,mms|supl", TYPE_DEFAULT | TYPE_MMS | TYPE_SUPL, "default,mms,supl"},
+		{" default , mms ", TYPE_DEFAULT | TYPE_MMS, "default,mms"},
+		{"de fault", TYPE_DEFAULT, "default"},
+		{"default,,mms,", TYPE_DEFAULT | TYPE_MMS, "default,mms"},
+		{"default,default", TYPE_DEFAULT, "default"},
+		{"*", TYPE_ALL, "*"},
+		{"*,default", TYPE_ALL, "*"},
+		{"default,mms,supl,dun,hipri,fota,ims,cbs", TYPE_ALL, "*"},
+		{"*,ia", TYPE_ALL | TYPE_IA, "default,mms,supl,dun,hipri,fota,ims,cbs,ia"},
+		{"ia", TYPE_IA, "ia"},
+		{"emergency", TYPE_EMERGENCY, "emergency"},
+		{"mcx", TYPE_MCX, "mcx"},
+		{"xcap", TYPE_XCAP, "xcap"},
+		{"vsim", TYPE_VSIM, "vsim"},
+		{"bip", TYPE_BIP, "bip"},
+		{"enterprise", TYPE_ENTERPRISE, "enterprise"},
+		{"rcs", TYPE_RCS, "rcs"},
+	}, []string{
+		"foo",
+		"default,foo",
+		"default;mms",
+		"1",
+		"all",
+	})
+	var all []Type
+	for v := TYPE_NONE; v < type_limit; v++ {
+		all = append(all, v)
+	}
+	testRoundTrip(t, all...)
+	testMarshalError(t, type_limit, -1)
+}
+
+func TestProtocolText(t *testing.T) {
+	testText[Protocol](t, []textCase[Protocol]{
+		{"IP", PROTOCOL_IP, "IP"},
+		{"ip", PROTOCOL_IP, "IP"},
+		{"IPV6", PROTOCOL_IPV6, "IPV6"},
+		{"IPv6", PROTOCOL_IPV6, "IPV6"},
+		{"IPV4V6", PROTOCOL_IPV4V6, "IPV4V6"},
+		{"ipv4v6", PROTOCOL_IPV4V6, "IPV4V6"},
+		{"PPP", PROTOCOL_PPP, "PPP"},
+		{"NON-IP", PROTOCOL_NON_IP, "NON-IP"},
+		{"non-ip", PROTOCOL_NON_IP, "NON-IP"},
+		{"UNSTRUCTURED", PROTOCOL_UNSTRUCTURED, "UNSTRUCTURED"},
+		{"Unstructured", PROTOCOL_UNSTRUCTURED, "UNSTRUCTURED"},
+	}, []string{
+		"",
+		"IPV4",
+		"NON_IP",
+		"0",
+		" IP",
+	})
+	testRoundTrip(t, PROTOCOL_IP, PROTOCOL_IPV6, PROTOCOL_IPV4V6, PROTOCOL_PPP, PROTOCOL_NON_IP, PROTOCOL_UNSTRUCTURED)
+	testMarshalError(t, PROTOCOL_UNKNOWN, PROTOCOL_UNSTRUCTURED+1)
+}
+
+func TestMVNOTypeText(t *testing.T) {
+	testText[MVNOType](t, []textCase[MVNOType]{
+		{"spn", MVNO_TYPE_SPN, "spn"},
+		{"SPN", MVNO_TYPE_SPN, "spn"},
+		{"imsi", MVNO_TYPE_IMSI, "imsi"},
+		{"IMSI", MVNO_TYPE_IMSI, "imsi"},
+		{"gid", MVNO_TYPE_GID, "gid"},
+		{"Gid", MVNO_TYPE_GID, "gid"},
+		{"iccid", MVNO_TYPE_ICCID, "iccid"},
+		{"ICCID", MVNO_TYPE_ICCID, "iccid"},
+	}, []string{
+		"",
+		"gid1",
+		"0",
+		"spn ",
+	})
+	testRoundTrip(t, MVNO_TYPE_SPN, MVNO_TYPE_IMSI, MVNO_TYPE_GID, MVNO_TYPE_ICCID)
+	testMarshalError(t, MVNO_TYPE_UNKNOWN, MVNO_TYPE_ICCID+1)
+}
+
+func TestInfrastructureText(t *testing.T) {
+	testText[Infrastructure](t, []textCase[Infrastructure]{
+		{"cellular", INFRASTRUCTURE_CELLULAR, "cellular"},
+		{"CELLULAR", INFRASTRUCTURE_CELLULAR, "cellular"},
+		{"satellite", INFRASTRUCTURE_SATELLITE, "satellite"},
+		{"Satellite", INFRASTRUCTURE_SATELLITE, "satellite"},
+		{"cellular|satellite", INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE, "cellular|satellite"},
+		{"satellite|cellular", INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE, "cellular|satellite"},
+		{" cellular | satellite ", INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE, "cellular|satellite"},
+		{"cellular|cellular", INFRASTRUCTURE_CELLULAR, "cellular"},
+	}, []string{
+		"",
+		"cellular,satellite",
+		"cellular|",
+		"wifi",
+		"1",
+	})
+	testRoundTrip(t, INFRASTRUCTURE_CELLULAR, INFRASTRUCTURE_SATELLITE, INFRASTRUCTURE_CELLULAR|INFRASTRUCTURE_SATELLITE)
+	testMarshalError[Infrastructure](t, 0, 4)
+}
+
+func TestAuthTypeText(t *testing.T) {
+	testText[AuthType](t, []textCase[AuthType]{
+		{"NONE", AUTH_TYPE_NONE, "NONE"},
+		{"none", AUTH_TYPE_NONE, "NONE"},
+		{"0", AUTH_TYPE_NONE, "NONE"},
+		{"PAP", AUTH_TYPE_PAP, "PAP"},
+		{"pap", AUTH_TYPE_PAP, "PAP"},
+		{"1", AUTH_TYPE_PAP, "PAP"},
+		{"CHAP", AUTH_TYPE_CHAP, "CHAP"},
+		{"chap", AUTH_TYPE_CHAP, "CHAP"},
+		{"2", AUTH_TYPE_CHAP, "CHAP"},
+		{"PAP_OR_CHAP", AUTH_TYPE_PAP_OR_CHAP, "PAP_OR_CHAP"},
+		{"pap_or_chap", AUTH_TYPE_PAP_OR_CHAP, "PAP_OR_CHAP"},
+		{"3", AUTH_TYPE_PAP_OR_CHAP, "PAP_OR_CHAP"},
+	}, []string{
+		"",
+		"-1",
+		"4",
+		"PAP|CHAP",
+		"01",
+	})
+	testRoundTrip(t, AUTH_TYPE_NONE, AUTH_TYPE_PAP, AUTH_TYPE_CHAP, AUTH_TYPE_PAP_OR_CHAP)
+	testMarshalError(t, AUTH_TYPE_UNKNOWN, AUTH_TYPE_PAP_OR_CHAP+1)
+}
+
+func TestSkip464XLATText(t *testing.T) {
+	testText[Skip464XLAT](t, []textCase[Skip464XLAT]{
+		{"DEFAULT", SKIP_464XLAT_DEFAULT, "DEFAULT"},
+		{"default", SKIP_464XLAT_DEFAULT, "DEFAULT"},
+		{"-1", SKIP_464XLAT_DEFAULT, "DEFAULT"},
+		{"DISABLE", SKIP_464XLAT_DISABLE, "DISABLE"},
+		{"disable", SKIP_464XLAT_DISABLE, "DISABLE"},
+		{"0", SKIP_464XLAT_DISABLE, "DISABLE"},
+		{"ENABLE", SKIP_464XLAT_ENABLE, "ENABLE"},
+		{"Enable", SKIP_464XLAT_ENABLE, "ENABLE"},
+		{"1", SKIP_464XLAT_ENABLE, "ENABLE"},
+	}, []string{
+		"",
+		"2",
+		"-2",
+		"true",
+	})
+	testRoundTrip(t, SKIP_464XLAT_DEFAULT, SKIP_464XLAT_DISABLE, SKIP_464XLAT_ENABLE)
+	testMarshalError(t, SKIP_464XLAT_DEFAULT-1, SKIP_464XLAT_ENABLE+1)
+}
+
+func TestNetworkTypeText(t *testing.T) {
+	testText[NetworkType](t, []textCase[NetworkType]{
+		{"LTE", NETWORK_TYPE_LTE, "LTE"},
+		{"lte", NETWORK_TYPE_LTE, "LTE"},
+		{"13", NETWORK_TYPE_LTE, "LTE"},
+		{"1xRTT", NETWORK_TYPE_1xRTT, "1xRTT"},
+		{"1XRTT", NETWORK_TYPE_1xRTT, "1xRTT"},
+		{"7", NETWORK_TYPE_1xRTT, "1xRTT"},
+		{"td_scdma", NETWORK_TYPE_TD_SCDMA, "TD_SCDMA"},
+		{"1", NETWORK_TYPE_GPRS, "GPRS"},
+		{"20", NETWORK_TYPE_NR, "NR"},
+	}, []string{
+		"",
+		"0",
+		"21",
+		"-1",
+		"UNKNOWN",
+		"5G",
+		" LTE",
+	})
+	var all []NetworkType
+	for v := NETWORK_TYPE_GPRS; v <= NETWORK_TYPE_NR; v++ {
+		all = append(all, v)
+	}
+	testRoundTrip(t, all...)
+	testMarshalError(t, NETWORK_TYPE_UNKNOWN, NETWORK_TYPE_NR+1)
+}
+
+func TestNetworkTypeBitmaskText(t *testing.T) {
+	testText[NetworkTypeBitmask](t, []textCase[NetworkTypeBitmask]{
+		{"", 0, ""},
+		{"0", 0, ""},
+		{" 0 ", 0, ""},
+		{"13", NETWORK_TYPE_BITMASK_LTE, "13"},
+		{"LTE", NETWORK_TYPE_BITMASK_LTE, "13"},
+		{"13|20", NETWORK_TYPE_BITMASK_LTE | NETWORK_TYPE_BITMASK_NR, "13|20"},
+		{"20|13", NETWORK_TYPE_BITMASK_LTE | NETWORK_TYPE_BITMASK_NR, "13|20"},
+		{"lte|NR", NETWORK_TYPE_BITMASK_LTE | NETWORK_TYPE_BITMASK_NR, "13|20"},
+		{" 13 | 20 ", NETWORK_TYPE_BITMASK_LTE | NETWORK_TYPE_BITMASK_NR, "13|20"},
+		{"13|13", NETWORK_TYPE_BITMASK_LTE, "13"},
+		{"1|20", NETWORK_TYPE_BITMASK_GPRS | NETWORK_TYPE_BITMASK_NR, "1|20"},
+	}, []string{
+		"13,20",
+		"0|13",
+		"13|",
+		"21",
+		"foo",
+	})
+	all := []NetworkTypeBitmask{0}
+	var every NetworkTypeBitmask
+	for v := NETWORK_TYPE_GPRS; v <= NETWORK_TYPE_NR; v++ {
+		all = append(all, MakeNetworkTypeBitmask(v))
+		every |= MakeNetworkTypeBitmask(v)
+	}
+	testRoundTrip(t, append(all, every)...)
+	testMarshalError(t, NETWORK_TYPE_BITMASK_NR<<1)
+}
@@ -0,0 +1,109 @@
+package apn
+
+// https://cs.android.com/android/platform/superproject/main/+/main:packages/providers/TelephonyProvider/src/com/android/providers/telephony/TelephonyProvider.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae (CARRIERS_UNIQUE_FIELDS)
+
+// Key identifies a Setting like the UNIQUE constraint on the TelephonyProvider
+// carriers table. Two settings with the same key can't both be inserted into
+// the database. It is computed from the normalized setting. The owned_by and
+// (deprecated) bearer columns are not represented.
+type Key struct {
+	OperatorNumeric string
+	APNName         string
+	ProxyAddress    string
+	ProxyPort       int
+	MMSProxyAddress string
+	MMSProxyPort    int
+	MMSC            string
+	CarrierEnabled  bool
+	MVNOType        MVNOType
+	MVNOMatchData   string
+	ProfileID       int
+	Protocol        Protocol
+	RoamingProtocol Protocol
+	UserEditable    bool
+	APNSetID        int
+	CarrierID       int
+}
+
+// Key returns the identity of s.
+func (s Setting) Key() Key {
+	s = s.Normalize()
+	return Key{
+		OperatorNumeric: s.OperatorNumeric,
+		APNName:         s.APNName,
+		ProxyAddress:    s.ProxyAddress,
+		ProxyPort:       s.ProxyPort,
+		MMSProxyAddress: s.MMSProxyAddress,
+		MMSProxyPort:    s.MMSProxyPort,
+		MMSC:            s.MMSC,
+		CarrierEnabled:  s.CarrierEnabled,
+		MVNOType:        s.MVNOType,
+		MVNOMatchData:   s.MVNOMatchData,
+		ProfileID:       s.ProfileID,
+		Protocol:        s.Protocol,
+		RoamingProtocol: s.RoamingProtocol,
+		UserEditable:    s.UserEditable,
+		APNSetID:        s.APNSetID,
+		CarrierID:       s.CarrierID,
+	}
+}
+
+// Deduped is a unique setting.
+type Deduped struct {
+	Setting Setting
+	Sources []int // indexes of the input settings which were merged into this one
+}
+
+// Conflict is a key shared by multiple settings which aren't equivalent.
+type Conflict struct {
+	Key     Key
+	Deduped []int     // indexes into the deduplicated settings
+	Changes []Changes // from the first setting to each of the other ones
+}
+
+// Dedup merges equivalent settings (i.e., with no differences according to
+// Diff). The first of each set of equivalent settings is kept, in the original
+// order. If multiple settings with the same key are not equivalent, they are
+// all kept, and a conflict is returned.
+func Dedup(ss []Setting) ([]Deduped, []Conflict) {
+	var (
+		dd    []Deduped
+		keys  []Key
+		byKey = map[Key][]int{} // [key]deduped
+	)
+	for i, s := range ss {
+		k := s.Key()
+		merged := false
+		for _, j := range byKey[k] {
+			if len(Diff(dd[j].Setting, s)) == 0 {
+				dd[j].Sources = append(dd[j].Sources, i)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			if len(byKey[k]) == 0 {
+				keys = append(keys, k)
+			}
+			byKey[k] = append(byKey[k], len(dd))
+			dd = append(dd, Deduped{
+				Setting: s,
+				Sources: []int{i},
+			})
+		}
+	}
+	var cs []Conflict
+	for _, k := range keys {
+		if js := byKey[k]; len(js) > 1 {
+			c := Conflict{
+				Key:     k,
+				Deduped: js,
+			}
+			for _, j := range js[1:] {
+				c.Changes = append(c.Changes, Diff(dd[js[0]].Setting, dd[j].Setting))
+			}
+			cs = append(cs, c)
+		}
+	}
+	return dd, cs
+}
@@ -0,0 +1,89 @@
+package apn
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	base := Empty()
+	base.OperatorNumeric = "302220"
+	base.APNName = "test"
+
+	for _, tc := range []struct {
+		Name  string
+		Apply func(s *Setting)
+		Same  bool
+	}{
+		{"EntryName", func(s *Setting) { s.EntryName = "Other" }, true},
+		{"Type", func(s *Setting) { s.APNTypeBitmask = TYPE_MMS }, true},
+		{"UnsetProtocol", func(s *Setting) { s.Protocol = PROTOCOL_IP }, true},
+		{"UnsetPort", func(s *Setting) { s.ProxyPort = 0 }, true},
+		{"MVNODataWithoutType", func(s *Setting) { s.MVNOMatchData = "x" }, true},
+		{"APNName", func(s *Setting) { s.APNName = "other" }, false},
+		{"Protocol", func(s *Setting) { s.Protocol = PROTOCOL_IPV6 }, false},
+		{"Port", func(s *Setting) { s.ProxyPort = 80 }, false},
+		{"MVNO", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "x" }, false},
+		{"CarrierID", func(s *Setting) { s.CarrierID = 1 }, false},
+		{"APNSetID", func(s *Setting) { s.APNSetID = 1 }, false},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := base
+			tc.Apply(&s)
+			if same := s.Key() == base.Key(); same != tc.Same {
+				t.Errorf("expected same key=%t, got %t", tc.Same, same)
+			}
+		})
+	}
+}
+
+func TestDedup(t *testing.T) {
+	mk := func(apply func(s *Setting)) Setting {
+		s := Empty()
+		s.EntryName = "Test"
+		s.OperatorNumeric = "302220"
+		s.APNName = "test"
+		s.APNTypeBitmask = TYPE_DEFAULT
+		if apply != nil {
+			apply(&s)
+		}
+		return s
+	}
+	ss := []Setting{
+		0: mk(nil),
+		1: mk(func(s *Setting) { s.APNName = "other" }),
+		2: mk(func(s *Setting) { s.Protocol = PROTOCOL_IP }),              // equivalent to 0
+		3: mk(func(s *Setting) { s.APNTypeBitmask = TYPE_MMS }),           // same key as 0
+		4: mk(func(s *Setting) { s.ProxyPort = 0 }),                       // equivalent to 0
+		5: mk(func(s *Setting) { s.APNTypeBitmask = TYPE_MMS }),           // equivalent to 3
+		6: mk(func(s *Setting) { s.EntryName, s.APNName = "X", "other" }), // same key as 1
+	}
+	dd, cs := Dedup(ss)
+
+	var sources [][]int
+	for _, d := range dd {
+		sources = append(sources, d.Sources)
+	}
+	if want := [][]int{{0, 2, 4}, {1}, {3, 5}, {6}}; !slices.EqualFunc(sources, want, slices.Equal) {
+		t.Errorf("expected sources %v, got %v", want, sources)
+	}
+	for i, d := range dd {
+		if d.Setting != ss[d.Sources[0]] {
+			t.Errorf("deduped setting %d is not the first source", i)
+		}
+	}
+
+	if len(cs) != 2 {
+		t.Fatalf("expected 2 conflicts, got %d", len(cs))
+	}
+	if c := cs[0]; c.Key != ss[0].Key() || !slices.Equal(c.Deduped, []int{0, 2}) || len(c.Changes) != 1 || len(c.Changes[0]) != 1 || c.Changes[0][0].Field != "APNTypeBitmask" {
+		t.Errorf("incorrect first conflict %+v", c)
+	}
+	if c := cs[1]; c.Key != ss[1].Key() || !slices.Equal(c.Deduped, []int{1, 3}) || len(c.Changes) != 1 || len(c.Changes[0]) != 1 || c.Changes[0][0].Field != "EntryName" {
+		t.Errorf("incorrect second conflict %+v", c)
+	}
+
+	if dd, cs := Dedup(nil); len(dd) != 0 || len(cs) != 0 {
+		t.Errorf("expected nothing for no settings, got %v %v", dd, cs)
+	}
+}
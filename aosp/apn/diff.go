@@ -0,0 +1,177 @@
+package apn
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+// Change is a difference in a single Setting field.
+type Change struct {
+	Field string // the Setting field name
+	Old   any    // the old value, with the same type as the field
+	New   any    // the new value, with the same type as the field
+
+	// For APNTypeBitmask (Type), NetworkTypeBitmask, and
+	// LingeringNetworkTypeBitmask (NetworkType), the individual values which
+	// were added or removed.
+	Added, Removed []any
+}
+
+// Changes is a list of changes between two settings.
+type Changes []Change
+
+// Diff compares a and b field by field, in the order the fields are declared.
+// Values which are equivalent to AOSP are considered equal (see Normalize).
+// BearerBitmask is not compared directly since it is only used to derive
+// NetworkTypeBitmask.
+func Diff(a, b Setting) Changes {
+	a, b = a.Normalize(), b.Normalize()
+
+	var (
+		cs Changes
+		av = reflect.ValueOf(a)
+		bv = reflect.ValueOf(b)
+	)
+	for i := range av.NumField() {
+		f := av.Type().Field(i)
+		if f.Name == "BearerBitmask" {
+			continue
+		}
+		x, y := av.Field(i).Interface(), bv.Field(i).Interface()
+		if x == y {
+			continue
+		}
+		c := Change{
+			Field: f.Name,
+			Old:   x,
+			New:   y,
+		}
+		switch x := x.(type) {
+		case Type:
+			c.Added, c.Removed = diffBitmask(x.Seq(), y.(Type).Seq())
+		case NetworkTypeBitmask:
+			c.Added, c.Removed = diffBitmask(x.Seq(), y.(NetworkTypeBitmask).Seq())
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func diffBitmask[T comparable](a, b iter.Seq[T]) (added, removed []any) {
+	x, y := map[T]bool{}, map[T]bool{}
+	for t := range a {
+		x[t] = true
+	}
+	for t := range b {
+		y[t] = true
+	}
+	for t := range b {
+		if !x[t] {
+			added = append(added, t)
+		}
+	}
+	for t := range a {
+		if !y[t] {
+			removed = append(removed, t)
+		}
+	}
+	return
+}
+
+// Normalize replaces values with the ones TelephonyProvider and ApnSetting
+// treat them as equivalent to:
+//
+//   - an unknown protocol or roaming protocol is IP (the db default)
+//   - an empty type bitmask is TYPE_ALL (getApnTypesBitmaskFromString)
+//   - a zero infrastructure bitmask is cellular|satellite (the db default)
+//   - a zero network type bitmask is derived from the bearer bitmask (makeApnSetting)
+//   - non-positive ports are -1, and non-positive mtus and carrier ids are 0
+//   - mvno match data is cleared if there isn't a mvno type (getRow)
+func (s Setting) Normalize() Setting {
+	if s.Protocol == PROTOCOL_UNKNOWN {
+		s.Protocol = PROTOCOL_IP
+	}
+	if s.RoamingProtocol == PROTOCOL_UNKNOWN {
+		s.RoamingProtocol = PROTOCOL_IP
+	}
+	if s.APNTypeBitmask == TYPE_NONE {
+		s.APNTypeBitmask = TYPE_ALL
+	}
+	if s.InfrastructureBitmask == 0 {
+		s.InfrastructureBitmask = INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE
+	}
+	if s.NetworkTypeBitmask == 0 {
+		s.NetworkTypeBitmask = ConvertBearerBitmaskToNetworkTypeBitmask(s.BearerBitmask)
+	}
+	if s.ProxyPort <= 0 {
+		s.ProxyPort = -1
+	}
+	if s.MMSProxyPort <= 0 {
+		s.MMSProxyPort = -1
+	}
+	if s.MTUv4 < 0 {
+		s.MTUv4 = 0
+	}
+	if s.MTUv6 < 0 {
+		s.MTUv6 = 0
+	}
+	if s.CarrierID < 0 {
+		s.CarrierID = 0
+	}
+	if s.MVNOType == MVNO_TYPE_UNKNOWN {
+		s.MVNOMatchData = ""
+	}
+	return s
+}
+
+// String formats the changes as a unified diff.
+func (cs Changes) String() string {
+	return cs.Format("a", "b")
+}
+
+// Format formats the changes as a unified diff between oldName and newName,
+// with one removed and added line per field. For bitmasks, the individual
+// values which were added and removed follow as a comment.
+func (cs Changes) Format(oldName, newName string) string {
+	var b strings.Builder
+	if len(cs) == 0 {
+		return ""
+	}
+	fmt.Fprintf(&b, "--- %s\n", oldName)
+	fmt.Fprintf(&b, "+++ %s\n", newName)
+	for _, c := range cs {
+		fmt.Fprintf(&b, "-%s: %s\n", c.Field, formatChangeValue(c.Old))
+		fmt.Fprintf(&b, "+%s: %s\n", c.Field, formatChangeValue(c.New))
+		if len(c.Added) != 0 || len(c.Removed) != 0 {
+			b.WriteString("#")
+			for _, v := range c.Added {
+				fmt.Fprintf(&b, " +%v", v)
+			}
+			for _, v := range c.Removed {
+				fmt.Fprintf(&b, " -%v", v)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func formatChangeValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case NetworkTypeBitmask:
+		var b strings.Builder
+		for t := range v.Seq() {
+			if b.Len() != 0 {
+				b.WriteByte('|')
+			}
+			b.WriteString(t.String())
+		}
+		return b.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,138 @@
+package apn
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		Apply func(s *Setting)
+		Want  func(s *Setting) // changes to the input, or nil if unchanged
+	}{
+		{"Unchanged", func(s *Setting) {}, nil},
+		{"Protocol", func(s *Setting) {
+			s.Protocol, s.RoamingProtocol = PROTOCOL_UNKNOWN, PROTOCOL_UNKNOWN
+		}, func(s *Setting) {
+			s.Protocol, s.RoamingProtocol = PROTOCOL_IP, PROTOCOL_IP
+		}},
+		{"ProtocolSet", func(s *Setting) {
+			s.Protocol, s.RoamingProtocol = PROTOCOL_IPV6, PROTOCOL_IPV4V6
+		}, nil},
+		{"Type", func(s *Setting) { s.APNTypeBitmask = TYPE_NONE }, func(s *Setting) { s.APNTypeBitmask = TYPE_ALL }},
+		{"TypeSet", func(s *Setting) { s.APNTypeBitmask = TYPE_IA }, nil},
+		{"Infrastructure", func(s *Setting) { s.InfrastructureBitmask = 0 }, func(s *Setting) {
+			s.InfrastructureBitmask = INFRASTRUCTURE_CELLULAR | INFRASTRUCTURE_SATELLITE
+		}},
+		{"InfrastructureSet", func(s *Setting) { s.InfrastructureBitmask = INFRASTRUCTURE_SATELLITE }, nil},
+		{"Ports", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = 0, -5 }, func(s *Setting) { s.ProxyPort, s.MMSProxyPort = -1, -1 }},
+		{"PortsSet", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = 1, 0xFFFF }, nil},
+		{"MTU", func(s *Setting) { s.MTUv4, s.MTUv6 = -1, -1 }, func(s *Setting) { s.MTUv4, s.MTUv6 = 0, 0 }},
+		{"CarrierID", func(s *Setting) { s.CarrierID = -1 }, func(s *Setting) { s.CarrierID = 0 }},
+		{"NetworkType", func(s *Setting) {
+			s.BearerBitmask = MakeBearerBitmask(RIL_RADIO_TECHNOLOGY_LTE)
+		}, func(s *Setting) {
+			s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_LTE)
+		}},
+		{"NetworkTypeSet", func(s *Setting) {
+			s.BearerBitmask = MakeBearerBitmask(RIL_RADIO_TECHNOLOGY_LTE)
+			s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_NR)
+		}, nil},
+		{"MVNO", func(s *Setting) { s.MVNOMatchData = "x" }, func(s *Setting) { s.MVNOMatchData = "" }},
+		{"MVNOSet", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "x" }, nil},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := Empty()
+			s.Protocol, s.RoamingProtocol = PROTOCOL_IP, PROTOCOL_IP
+			s.APNTypeBitmask = TYPE_DEFAULT
+			tc.Apply(&s)
+
+			want := s
+			if tc.Want != nil {
+				tc.Want(&want)
+			}
+			if got := s.Normalize(); got != want {
+				t.Errorf("incorrect result\nexpected %+v\n     got %+v", want, got)
+			}
+			if got := want.Normalize(); got != want {
+				t.Errorf("not idempotent\nexpected %+v\n     got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := Empty()
+	base.EntryName = "Test"
+	base.APNName = "test"
+	base.APNTypeBitmask = TYPE_DEFAULT | TYPE_MMS
+	base.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_LTE)
+
+	for _, tc := range []struct {
+		Name   string
+		Apply  func(s *Setting)
+		Fields []string
+	}{
+		{"Equal", func(s *Setting) {}, nil},
+		{"Equivalent", func(s *Setting) {
+			s.Protocol = PROTOCOL_IP
+			s.ProxyPort = 0
+			s.InfrastructureBitmask = 0
+			s.MVNOMatchData = "ignored"
+		}, nil},
+		{"BearerOnly", func(s *Setting) {
+			s.NetworkTypeBitmask = 0
+			s.BearerBitmask = MakeBearerBitmask(RIL_RADIO_TECHNOLOGY_LTE)
+		}, nil},
+		{"Fields", func(s *Setting) {
+			s.APNName = "other"
+			s.Protocol = PROTOCOL_IPV6
+			s.UserVisible = false
+		}, []string{"APNName", "Protocol", "UserVisible"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := base
+			tc.Apply(&s)
+			var fields []string
+			for _, c := range Diff(base, s) {
+				fields = append(fields, c.Field)
+			}
+			if !slices.Equal(fields, tc.Fields) {
+				t.Errorf("expected changed fields %q, got %q", tc.Fields, fields)
+			}
+		})
+	}
+
+	t.Run("Bitmask", func(t *testing.T) {
+		s := base
+		s.APNTypeBitmask = TYPE_DEFAULT | TYPE_SUPL | TYPE_IA
+		s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_NR)
+		cs := Diff(base, s)
+		if len(cs) != 2 {
+			t.Fatalf("expected 2 changes, got %v", cs)
+		}
+		if c := cs[0]; c.Field != "APNTypeBitmask" || c.Old != base.APNTypeBitmask || c.New != s.APNTypeBitmask ||
+			!slices.Equal(c.Added, []any{TYPE_SUPL, TYPE_IA}) || !slices.Equal(c.Removed, []any{TYPE_MMS}) {
+			t.Errorf("incorrect type change %+v", c)
+		}
+		if c := cs[1]; c.Field != "NetworkTypeBitmask" ||
+			!slices.Equal(c.Added, []any{NETWORK_TYPE_NR}) || !slices.Equal(c.Removed, []any{NETWORK_TYPE_LTE}) {
+			t.Errorf("incorrect network type change %+v", c)
+		}
+		if want, got := "--- a\n"+
+			"+++ b\n"+
+			"-APNTypeBitmask: default,mms\n"+
+			"+APNTypeBitmask: default,supl,ia\n"+
+			"# +supl +ia -mms\n"+
+			"-NetworkTypeBitmask: LTE\n"+
+			"+NetworkTypeBitmask: NR\n"+
+			"# +NR -LTE\n", cs.String(); got != want {
+			t.Errorf("incorrect format\nexpected:\n%s\ngot:\n%s", want, got)
+		}
+	})
+
+	if s := Changes(nil).String(); s != "" {
+		t.Errorf("expected no output for no changes, got %q", s)
+	}
+}
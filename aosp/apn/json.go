@@ -0,0 +1,269 @@
+package apn
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// settingJSON is the JSON representation of a Setting.
+type settingJSON struct {
+	EntryName                   string `json:"entry_name,omitempty"`
+	APNName                     string `json:"apn_name,omitempty"`
+	ProxyAddress                string `json:"proxy_address,omitempty"`
+	ProxyPort                   *int   `json:"proxy_port,omitempty"`
+	MMSC                        string `json:"mmsc,omitempty"`
+	MMSProxyAddress             string `json:"mms_proxy_address,omitempty"`
+	MMSProxyPort                *int   `json:"mms_proxy_port,omitempty"`
+	User                        string `json:"user,omitempty"`
+	Password                    string `json:"password,omitempty"`
+	AuthType                    string `json:"auth_type,omitempty"`
+	APNTypeBitmask              string `json:"apn_type,omitempty"`
+	OperatorNumeric             string `json:"operator_numeric,omitempty"`
+	Protocol                    string `json:"protocol,omitempty"`
+	RoamingProtocol             string `json:"roaming_protocol,omitempty"`
+	MTUv4                       int    `json:"mtu_v4,omitempty"`
+	MTUv6                       int    `json:"mtu_v6,omitempty"`
+	CarrierEnabled              bool   `json:"carrier_enabled,omitempty"`
+	ProfileID                   int    `json:"profile_id,omitempty"`
+	NetworkTypeBitmask          string `json:"network_type_bitmask,omitempty"`
+	LingeringNetworkTypeBitmask string `json:"lingering_network_type_bitmask,omitempty"`
+	Persistent                  bool   `json:"persistent,omitempty"`
+	MaxConns                    int    `json:"max_conns,omitempty"`
+	WaitTime                    int    `json:"wait_time,omitempty"`
+	MaxConnsTime                int    `json:"max_conns_time,omitempty"`
+	MVNOType                    string `json:"mvno_type,omitempty"`
+	MVNOMatchData               string `json:"mvno_match_data,omitempty"`
+	APNSetID                    int    `json:"apn_set_id,omitempty"`
+	CarrierID                   int    `json:"carrier_id,omitempty"`
+	Skip464XLAT                 string `json:"skip_464xlat,omitempty"`
+	AlwaysOn                    bool   `json:"always_on,omitempty"`
+	InfrastructureBitmask       string `json:"infrastructure_bitmask,omitempty"`
+	ESIMBootstrapProvisioning   bool   `json:"esim_bootstrap_provisioning,omitempty"`
+	Server                      string `json:"server,omitempty"`
+	UserVisible                 *bool  `json:"user_visible,omitempty"`
+	UserEditable                *bool  `json:"user_editable,omitempty"`
+	BearerBitmask               string `json:"bearer_bitmask,omitempty"`
+}
+
+// MarshalJSON encodes s as a JSON object with snake_case keys. Fields which are
+// equal to the value from Empty are omitted, so ports of -1 and unset enums
+// don't appear in the output. Enums and bitmasks are written as strings:
+//
+//   - apn_type: "default,mms" or "*" (as in apns-conf.xml)
+//   - auth_type: "NONE", "PAP", "CHAP", "PAP_OR_CHAP"
+//   - protocol, roaming_protocol: "IP", "IPV6", "IPV4V6", "PPP", "NON-IP", "UNSTRUCTURED"
+//   - network_type_bitmask, lingering_network_type_bitmask: "LTE|NR" (NetworkType names, numbers are also accepted)
+//   - bearer_bitmask: "LTE|NR" (RILRadioTechnology names, numbers are also accepted)
+//   - mvno_type: "spn", "imsi", "gid", "iccid"
+//   - skip_464xlat: "DISABLE", "ENABLE"
+//   - infrastructure_bitmask: "cellular", "satellite"
+//
+// A zero InfrastructureBitmask is treated as the default.
+func (s Setting) MarshalJSON() ([]byte, error) {
+	var (
+		e   = Empty()
+		j   settingJSON
+		err error
+	)
+	j.EntryName = s.EntryName
+	j.APNName = s.APNName
+	j.ProxyAddress = s.ProxyAddress
+	if s.ProxyPort != e.ProxyPort {
+		j.ProxyPort = &s.ProxyPort
+	}
+	j.MMSC = s.MMSC
+	j.MMSProxyAddress = s.MMSProxyAddress
+	if s.MMSProxyPort != e.MMSProxyPort {
+		j.MMSProxyPort = &s.MMSProxyPort
+	}
+	j.User = s.User
+	j.Password = s.Password
+	if s.AuthType != e.AuthType {
+		if j.AuthType, err = marshalTextString(s.AuthType); err != nil {
+			return nil, fmt.Errorf("auth_type: %w", err)
+		}
+	}
+	if s.APNTypeBitmask != e.APNTypeBitmask {
+		if j.APNTypeBitmask, err = marshalTextString(s.APNTypeBitmask); err != nil {
+			return nil, fmt.Errorf("apn_type: %w", err)
+		}
+	}
+	j.OperatorNumeric = s.OperatorNumeric
+	if s.Protocol != e.Protocol {
+		if j.Protocol, err = marshalTextString(s.Protocol); err != nil {
+			return nil, fmt.Errorf("protocol: %w", err)
+		}
+	}
+	if s.RoamingProtocol != e.RoamingProtocol {
+		if j.RoamingProtocol, err = marshalTextString(s.RoamingProtocol); err != nil {
+			return nil, fmt.Errorf("roaming_protocol: %w", err)
+		}
+	}
+	j.MTUv4 = s.MTUv4
+	j.MTUv6 = s.MTUv6
+	j.CarrierEnabled = s.CarrierEnabled
+	j.ProfileID = s.ProfileID
+	if j.NetworkTypeBitmask, err = marshalBitmaskNames(s.NetworkTypeBitmask.Seq()); err != nil {
+		return nil, fmt.Errorf("network_type_bitmask: %w", err)
+	}
+	if j.LingeringNetworkTypeBitmask, err = marshalBitmaskNames(s.LingeringNetworkTypeBitmask.Seq()); err != nil {
+		return nil, fmt.Errorf("lingering_network_type_bitmask: %w", err)
+	}
+	j.Persistent = s.Persistent
+	j.MaxConns = s.MaxConns
+	j.WaitTime = s.WaitTime
+	j.MaxConnsTime = s.MaxConnsTime
+	if s.MVNOType != e.MVNOType {
+		if j.MVNOType, err = marshalTextString(s.MVNOType); err != nil {
+			return nil, fmt.Errorf("mvno_type: %w", err)
+		}
+	}
+	j.MVNOMatchData = s.MVNOMatchData
+	j.APNSetID = s.APNSetID
+	j.CarrierID = s.CarrierID
+	if s.Skip464XLAT != e.Skip464XLAT {
+		if j.Skip464XLAT, err = marshalTextString(s.Skip464XLAT); err != nil {
+			return nil, fmt.Errorf("skip_464xlat: %w", err)
+		}
+	}
+	j.AlwaysOn = s.AlwaysOn
+	if s.InfrastructureBitmask != e.InfrastructureBitmask && s.InfrastructureBitmask != 0 { // zero is treated as the default
+		if j.InfrastructureBitmask, err = marshalTextString(s.InfrastructureBitmask); err != nil {
+			return nil, fmt.Errorf("infrastructure_bitmask: %w", err)
+		}
+	}
+	j.ESIMBootstrapProvisioning = s.ESIMBootstrapProvisioning
+	j.Server = s.Server
+	if s.UserVisible != e.UserVisible {
+		j.UserVisible = &s.UserVisible
+	}
+	if s.UserEditable != e.UserEditable {
+		j.UserEditable = &s.UserEditable
+	}
+	if j.BearerBitmask, err = marshalBitmaskNames(s.BearerBitmask.Seq()); err != nil {
+		return nil, fmt.Errorf("bearer_bitmask: %w", err)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a JSON object into s, starting with the values from
+// Empty. Unknown fields are rejected.
+func (s *Setting) UnmarshalJSON(b []byte) error {
+	var j settingJSON
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&j); err != nil {
+		return err
+	}
+	x := Empty()
+	x.EntryName = j.EntryName
+	x.APNName = j.APNName
+	x.ProxyAddress = j.ProxyAddress
+	if j.ProxyPort != nil {
+		x.ProxyPort = *j.ProxyPort
+	}
+	x.MMSC = j.MMSC
+	x.MMSProxyAddress = j.MMSProxyAddress
+	if j.MMSProxyPort != nil {
+		x.MMSProxyPort = *j.MMSProxyPort
+	}
+	x.User = j.User
+	x.Password = j.Password
+	if v := j.AuthType; v != "" {
+		if err := x.AuthType.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("auth_type: %w", err)
+		}
+	}
+	if v := j.APNTypeBitmask; v != "" {
+		if err := x.APNTypeBitmask.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("apn_type: %w", err)
+		}
+	}
+	x.OperatorNumeric = j.OperatorNumeric
+	if v := j.Protocol; v != "" {
+		if err := x.Protocol.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("protocol: %w", err)
+		}
+	}
+	if v := j.RoamingProtocol; v != "" {
+		if err := x.RoamingProtocol.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("roaming_protocol: %w", err)
+		}
+	}
+	x.MTUv4 = j.MTUv4
+	x.MTUv6 = j.MTUv6
+	x.CarrierEnabled = j.CarrierEnabled
+	x.ProfileID = j.ProfileID
+	if v := j.NetworkTypeBitmask; v != "" {
+		if err := x.NetworkTypeBitmask.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("network_type_bitmask: %w", err)
+		}
+	}
+	if v := j.LingeringNetworkTypeBitmask; v != "" {
+		if err := x.LingeringNetworkTypeBitmask.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("lingering_network_type_bitmask: %w", err)
+		}
+	}
+	x.Persistent = j.Persistent
+	x.MaxConns = j.MaxConns
+	x.WaitTime = j.WaitTime
+	x.MaxConnsTime = j.MaxConnsTime
+	if v := j.MVNOType; v != "" {
+		if err := x.MVNOType.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("mvno_type: %w", err)
+		}
+	}
+	x.MVNOMatchData = j.MVNOMatchData
+	x.APNSetID = j.APNSetID
+	x.CarrierID = j.CarrierID
+	if v := j.Skip464XLAT; v != "" {
+		if err := x.Skip464XLAT.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("skip_464xlat: %w", err)
+		}
+	}
+	x.AlwaysOn = j.AlwaysOn
+	if v := j.InfrastructureBitmask; v != "" {
+		if err := x.InfrastructureBitmask.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("infrastructure_bitmask: %w", err)
+		}
+	}
+	x.ESIMBootstrapProvisioning = j.ESIMBootstrapProvisioning
+	x.Server = j.Server
+	if j.UserVisible != nil {
+		x.UserVisible = *j.UserVisible
+	}
+	if j.UserEditable != nil {
+		x.UserEditable = *j.UserEditable
+	}
+	if v := j.BearerBitmask; v != "" {
+		if err := x.BearerBitmask.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("bearer_bitmask: %w", err)
+		}
+	}
+	*s = x
+	return nil
+}
+
+func marshalTextString[T interface{ MarshalText() ([]byte, error) }](x T) (string, error) {
+	b, err := x.MarshalText()
+	return string(b), err
+}
+
+// marshalBitmaskNames joins the names of the values in seq with '|'.
+func marshalBitmaskNames[T interface{ MarshalText() ([]byte, error) }](seq iter.Seq[T]) (string, error) {
+	var b strings.Builder
+	for t := range seq {
+		v, err := t.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		if b.Len() != 0 {
+			b.WriteByte('|')
+		}
+		b.Write(v)
+	}
+	return b.String(), nil
+}
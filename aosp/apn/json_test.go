@@ -0,0 +1,131 @@
+package apn
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSettingJSON(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		Apply func(s *Setting)
+		JSON  string
+	}{
+		{"Empty", func(s *Setting) {}, `{}`},
+		{"Sentinels", func(s *Setting) {
+			// these are equal to Empty, so they aren't written
+			s.ProxyPort, s.MMSProxyPort = -1, -1
+			s.AuthType = AUTH_TYPE_UNKNOWN
+			s.Protocol, s.RoamingProtocol = PROTOCOL_UNKNOWN, PROTOCOL_UNKNOWN
+			s.MVNOType = MVNO_TYPE_UNKNOWN
+			s.Skip464XLAT = SKIP_464XLAT_DEFAULT
+			s.UserVisible, s.UserEditable = true, true
+		}, `{}`},
+		{"ZeroInfrastructure", func(s *Setting) { s.InfrastructureBitmask = 0 }, `{}`},
+		{"ZeroPorts", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = 0, 0 }, `{"proxy_port":0,"mms_proxy_port":0}`},
+		{"AuthTypeNone", func(s *Setting) { s.AuthType = AUTH_TYPE_NONE }, `{"auth_type":"NONE"}`},
+		{"Hidden", func(s *Setting) { s.UserVisible, s.UserEditable = false, false }, `{"user_visible":false,"user_editable":false}`},
+		{"Full", func(s *Setting) {
+			s.EntryName = "Test"
+			s.APNName = "test"
+			s.ProxyAddress = "proxy"
+			s.ProxyPort = 8080
+			s.MMSC = "http://mmsc"
+			s.MMSProxyAddress = "mmsproxy"
+			s.MMSProxyPort = 80
+			s.User = "user"
+			s.Password = "pass"
+			s.AuthType = AUTH_TYPE_PAP_OR_CHAP
+			s.APNTypeBitmask = TYPE_DEFAULT | TYPE_MMS
+			s.OperatorNumeric = "302220"
+			s.Protocol = PROTOCOL_IPV4V6
+			s.RoamingProtocol = PROTOCOL_IP
+			s.MTUv4 = 1400
+			s.MTUv6 = 1280
+			s.CarrierEnabled = true
+			s.ProfileID = 1
+			s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_LTE, NETWORK_TYPE_NR)
+			s.LingeringNetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_LTE)
+			s.Persistent = true
+			s.MaxConns = 2
+			s.WaitTime = 3
+			s.MaxConnsTime = 4
+			s.MVNOType = MVNO_TYPE_GID
+			s.MVNOMatchData = "ba"
+			s.APNSetID = 5
+			s.CarrierID = 1
+			s.Skip464XLAT = SKIP_464XLAT_ENABLE
+			s.AlwaysOn = true
+			s.InfrastructureBitmask = INFRASTRUCTURE_CELLULAR
+			s.ESIMBootstrapProvisioning = true
+			s.Server = "*"
+			s.UserVisible = false
+			s.UserEditable = false
+			s.BearerBitmask = MakeBearerBitmask(RIL_RADIO_TECHNOLOGY_LTE)
+		}, `{"entry_name":"Test","apn_name":"test","proxy_address":"proxy","proxy_port":8080,"mmsc":"http://mmsc","mms_proxy_address":"mmsproxy","mms_proxy_port":80,"user":"user","password":"pass","auth_type":"PAP_OR_CHAP","apn_type":"default,mms","operator_numeric":"302220","protocol":"IPV4V6","roaming_protocol":"IP","mtu_v4":1400,"mtu_v6":1280,"carrier_enabled":true,"profile_id":1,"network_type_bitmask":"LTE|NR","lingering_network_type_bitmask":"LTE","persistent":true,"max_conns":2,"wait_time":3,"max_conns_time":4,"mvno_type":"gid","mvno_match_data":"ba","apn_set_id":5,"carrier_id":1,"skip_464xlat":"ENABLE","always_on":true,"infrastructure_bitmask":"cellular","esim_bootstrap_provisioning":true,"server":"*","user_visible":false,"user_editable":false,"bearer_bitmask":"LTE"}`},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := Empty()
+			tc.Apply(&s)
+
+			b, err := json.Marshal(s)
+			if err != nil {
+				t.Fatalf("marshal: unexpected error: %v", err)
+			}
+			if string(b) != tc.JSON {
+				t.Errorf("marshal: expected %s, got %s", tc.JSON, b)
+			}
+
+			var r Setting
+			if err := json.Unmarshal(b, &r); err != nil {
+				t.Fatalf("unmarshal: unexpected error: %v", err)
+			}
+			if s.InfrastructureBitmask == 0 {
+				s.InfrastructureBitmask = Empty().InfrastructureBitmask // zero is written as the default
+			}
+			if r != s {
+				t.Errorf("unmarshal: expected %+v, got %+v", s, r)
+			}
+
+			b2, err := json.Marshal(r)
+			if err != nil {
+				t.Fatalf("marshal again: unexpected error: %v", err)
+			}
+			if string(b2) != string(b) {
+				t.Errorf("marshal again: expected %s, got %s", b, b2)
+			}
+		})
+	}
+}
+
+func TestSettingJSONReject(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		JSON  string
+		Error string // substring
+	}{
+		{"UnknownField", `{"entry_name":"Test","mcc":"302"}`, `unknown field "mcc"`},
+		{"AuthType", `{"auth_type":"NTLM"}`, "auth_type"},
+		{"APNType", `{"apn_type":"default,invalid"}`, "apn_type"},
+		{"Protocol", `{"protocol":"IPX"}`, "protocol"},
+		{"NetworkType", `{"network_type_bitmask":"LTE|5G"}`, "network_type_bitmask"},
+		{"MVNOType", `{"mvno_type":"plmn"}`, "mvno_type"},
+		{"Skip464XLAT", `{"skip_464xlat":"MAYBE"}`, "skip_464xlat"},
+		{"Infrastructure", `{"infrastructure_bitmask":"wifi"}`, "infrastructure_bitmask"},
+		{"Type", `{"proxy_port":"80"}`, "proxy_port"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := Empty()
+			s.EntryName = "Unchanged"
+			if err := json.Unmarshal([]byte(tc.JSON), &s); err == nil {
+				t.Errorf("expected error, got %+v", s)
+			} else if !strings.Contains(err.Error(), tc.Error) {
+				t.Errorf("expected error containing %q, got %v", tc.Error, err)
+			}
+			if s.EntryName != "Unchanged" {
+				t.Errorf("setting changed on error to %+v", s)
+			}
+		})
+	}
+}
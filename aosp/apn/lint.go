@@ -0,0 +1,235 @@
+package apn
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Severity is the severity of a lint finding.
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // probably intentional, but worth reviewing
+	SeverityWarning                 // probably a mistake
+	SeverityError                   // rejected or misinterpreted by AOSP
+)
+
+func (x Severity) Valid() bool {
+	return x.String() != ""
+}
+
+func (x Severity) String() string {
+	b, _ := x.MarshalText()
+	return string(b)
+}
+
+func (x Severity) MarshalText() ([]byte, error) {
+	switch x {
+	case SeverityInfo:
+		return []byte("info"), nil
+	case SeverityWarning:
+		return []byte("warning"), nil
+	case SeverityError:
+		return []byte("error"), nil
+	default:
+		return nil, fmt.Errorf("unknown severity %#v", x)
+	}
+}
+
+func (x *Severity) UnmarshalText(t []byte) error {
+	switch t := strings.ToLower(string(t)); t {
+	case "info":
+		*x = SeverityInfo
+	case "warning", "warn":
+		*x = SeverityWarning
+	case "error":
+		*x = SeverityError
+	default:
+		return fmt.Errorf("unknown severity %q", t)
+	}
+	return nil
+}
+
+// Rule is a lint rule for a Setting.
+type Rule struct {
+	ID       string   // stable identifier, in kebab-case
+	Severity Severity // severity of the findings
+	Check    func(s Setting) []string
+}
+
+// Finding is a problem found by a Rule.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Severity, f.Message, f.Rule)
+}
+
+// Lint checks s against rules, returning every finding in the order of the
+// rules. If rules is nil, DefaultRules is used.
+func Lint(s Setting, rules []Rule) []Finding {
+	if rules == nil {
+		rules = DefaultRules()
+	}
+	var fs []Finding
+	for _, r := range rules {
+		for _, msg := range r.Check(s) {
+			fs = append(fs, Finding{
+				Rule:     r.ID,
+				Severity: r.Severity,
+				Message:  msg,
+			})
+		}
+	}
+	return fs
+}
+
+// MaxSeverity returns the highest severity of fs, and false if fs is empty.
+func MaxSeverity(fs []Finding) (Severity, bool) {
+	if len(fs) == 0 {
+		return 0, false
+	}
+	return slices.MaxFunc(fs, func(a, b Finding) int {
+		return int(a.Severity) - int(b.Severity)
+	}).Severity, true
+}
+
+// DefaultRules returns a new slice containing the built-in rules. The first
+// ones are the same as ApnSetting.build.
+func DefaultRules() []Rule {
+	return []Rule{
+		{"entry-name-required", SeverityError, lintEntryNameRequired},
+		{"apn-name-required", SeverityError, lintAPNNameRequired},
+		{"type-invalid", SeverityError, lintTypeInvalid},
+		{"mms-proxy-url", SeverityError, lintMMSProxyURL},
+		{"bearer-network-mismatch", SeverityError, lintBearerNetworkMismatch},
+		{"mms-without-mmsc", SeverityWarning, lintMMSWithoutMMSC},
+		{"mmsc-invalid-url", SeverityError, lintMMSCInvalidURL},
+		{"port-range", SeverityError, lintPortRange},
+		{"ipv6-mtu-range", SeverityWarning, lintIPv6MTURange},
+		{"ia-user-visible", SeverityInfo, lintIAUserVisible},
+		{"mvno-match-data-required", SeverityError, lintMVNOMatchDataRequired},
+		{"ipv6-only-skip-464xlat", SeverityWarning, lintIPv6OnlySkip464XLAT},
+		{"type-all-mixed", SeverityInfo, lintTypeAllMixed},
+	}
+}
+
+func lintEntryNameRequired(s Setting) []string {
+	if s.EntryName == "" {
+		return []string{"entry name is required"}
+	}
+	return nil
+}
+
+func lintAPNNameRequired(s Setting) []string {
+	if s.APNName == "" {
+		return []string{"apn name is required"}
+	}
+	return nil
+}
+
+func lintTypeInvalid(s Setting) []string {
+	if !s.APNTypeBitmask.Valid() {
+		return []string{fmt.Sprintf("invalid apn type bitmask %b", s.APNTypeBitmask)}
+	}
+	return nil
+}
+
+func lintMMSProxyURL(s Setting) []string {
+	if s.APNTypeBitmask&TYPE_MMS != 0 {
+		if u, err := url.Parse(s.MMSProxyAddress); err == nil && u.Scheme != "" {
+			return []string{"mms proxy should be a hostname, not a url"}
+		}
+	}
+	return nil
+}
+
+func lintBearerNetworkMismatch(s Setting) []string {
+	// extra: check that bearer bitmask is in sync with network type bitmask if set
+	if s.BearerBitmask != 0 {
+		if x, y := ConvertBearerBitmaskToNetworkTypeBitmask(s.BearerBitmask), s.NetworkTypeBitmask; x != y {
+			return []string{fmt.Sprintf("overridden bearer bitmask converted to a network bitmask (lossy) is %s, but the network type bitmask is set to %s", slices.Collect(x.Seq()), slices.Collect(y.Seq()))}
+		}
+	}
+	return nil
+}
+
+func lintMMSWithoutMMSC(s Setting) []string {
+	if s.APNTypeBitmask&TYPE_MMS != 0 && s.APNTypeBitmask&TYPE_ALL != TYPE_ALL && s.MMSC == "" { // not for *, which includes mms
+		return []string{"mms type without mmsc"}
+	}
+	return nil
+}
+
+func lintMMSCInvalidURL(s Setting) []string {
+	if s.MMSC != "" {
+		if u, err := url.Parse(s.MMSC); err != nil {
+			return []string{fmt.Sprintf("invalid mmsc url: %v", err)}
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			return []string{fmt.Sprintf("mmsc url %q is not http or https", s.MMSC)}
+		} else if u.Host == "" {
+			return []string{fmt.Sprintf("mmsc url %q does not have a host", s.MMSC)}
+		}
+	}
+	return nil
+}
+
+func lintPortRange(s Setting) []string {
+	var msgs []string
+	if v := s.ProxyPort; v > 0xFFFF { // non-positive ports are unset (see Normalize)
+		msgs = append(msgs, fmt.Sprintf("proxy port %d out of range", v))
+	}
+	if v := s.MMSProxyPort; v > 0xFFFF {
+		msgs = append(msgs, fmt.Sprintf("mms proxy port %d out of range", v))
+	}
+	return msgs
+}
+
+func lintIPv6MTURange(s Setting) []string {
+	isV6 := func(p Protocol) bool {
+		return p == PROTOCOL_IPV6 || p == PROTOCOL_IPV4V6
+	}
+	if v := s.MTUv6; v > 0 && (v < 1280 || v > 1500) && (isV6(s.Protocol) || isV6(s.RoamingProtocol)) {
+		// RFC 8200 minimum link mtu, and the usual ethernet maximum
+		return []string{fmt.Sprintf("ipv6 mtu %d is outside 1280-1500", v)}
+	}
+	return nil
+}
+
+func lintIAUserVisible(s Setting) []string {
+	if s.APNTypeBitmask&TYPE_IA != 0 && s.UserVisible {
+		return []string{"initial attach apn is user-visible"}
+	}
+	return nil
+}
+
+func lintMVNOMatchDataRequired(s Setting) []string {
+	if s.MVNOType != MVNO_TYPE_UNKNOWN && s.MVNOMatchData == "" {
+		return []string{fmt.Sprintf("mvno type %s without match data (ignored by TelephonyProvider)", s.MVNOType)}
+	}
+	return nil
+}
+
+func lintIPv6OnlySkip464XLAT(s Setting) []string {
+	if s.APNTypeBitmask&TYPE_DEFAULT != 0 && s.Skip464XLAT == SKIP_464XLAT_ENABLE {
+		if s.Protocol == PROTOCOL_IPV6 || s.RoamingProtocol == PROTOCOL_IPV6 {
+			return []string{"ipv6-only default apn skips 464xlat, so ipv4-only apps won't have connectivity"}
+		}
+	}
+	return nil
+}
+
+// lintTypeAllMixed checks for types outside TYPE_ALL (e.g., ia) combined with
+// all of TYPE_ALL. Since "*" is the same as listing every type in TYPE_ALL,
+// explicit types within it can't be detected from the bitmask.
+func lintTypeAllMixed(s Setting) []string {
+	if v := s.APNTypeBitmask; v&TYPE_ALL == TYPE_ALL && v&^TYPE_ALL != 0 {
+		return []string{fmt.Sprintf("all types (*) combined with explicit types %s", v&^TYPE_ALL)}
+	}
+	return nil
+}
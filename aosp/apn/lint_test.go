@@ -0,0 +1,47 @@
+package apn
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	base := Empty()
+	base.EntryName = "Test"
+	base.APNName = "test"
+	base.APNTypeBitmask = TYPE_DEFAULT
+	base.UserVisible = false
+
+	for _, tc := range []struct {
+		Name  string
+		Apply func(s *Setting)
+		Rules []string // ids of the expected findings
+	}{
+		{"Valid", func(s *Setting) {}, nil},
+		{"TypeAll", func(s *Setting) { s.APNTypeBitmask = TYPE_ALL }, nil},
+		{"TypeAllIA", func(s *Setting) { s.APNTypeBitmask = TYPE_ALL | TYPE_IA }, []string{"type-all-mixed"}},
+		{"TypeAllEmergency", func(s *Setting) { s.APNTypeBitmask = TYPE_ALL | TYPE_EMERGENCY }, []string{"type-all-mixed"}},
+		{"TypeIA", func(s *Setting) { s.APNTypeBitmask = TYPE_DEFAULT | TYPE_IA }, nil},
+		{"TypeIAUserVisible", func(s *Setting) { s.APNTypeBitmask, s.UserVisible = TYPE_IA, true }, []string{"ia-user-visible"}},
+		{"MMSWithoutMMSC", func(s *Setting) { s.APNTypeBitmask = TYPE_MMS }, []string{"mms-without-mmsc"}},
+		{"PortUnset", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = -1, -1 }, nil},
+		{"PortZero", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = 0, 0 }, nil},
+		{"PortValid", func(s *Setting) { s.ProxyPort, s.MMSProxyPort = 1, 0xFFFF }, nil},
+		{"PortTooLarge", func(s *Setting) { s.ProxyPort = 0x10000 }, []string{"port-range"}},
+		{"MVNOWithoutData", func(s *Setting) { s.MVNOType = MVNO_TYPE_SPN }, []string{"mvno-match-data-required"}},
+		{"MVNOWithData", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "x" }, nil},
+		{"Required", func(s *Setting) { s.EntryName, s.APNName = "", "" }, []string{"entry-name-required", "apn-name-required"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			s := base
+			tc.Apply(&s)
+			var rules []string
+			for _, f := range Lint(s, nil) {
+				rules = append(rules, f.Rule)
+			}
+			if !slices.Equal(rules, tc.Rules) {
+				t.Errorf("expected findings %q, got %q", tc.Rules, rules)
+			}
+		})
+	}
+}
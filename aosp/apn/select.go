@@ -0,0 +1,228 @@
+package apn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// https://cs.android.com/android/platform/superproject/main/+/main:packages/providers/TelephonyProvider/src/com/android/providers/telephony/TelephonyProvider.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae (getSubscriptionMatchingAPNList)
+// https://cs.android.com/android/platform/superproject/main/+/main:frameworks/opt/telephony/src/java/com/android/internal/telephony/data/DataProfileManager.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae (getDataProfileForNetworkRequest)
+// https://cs.android.com/android/platform/superproject/main/+/main:frameworks/opt/telephony/src/java/com/android/internal/telephony/data/ApnSettingUtils.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae (mvnoMatches)
+
+const (
+	NO_APN_SET_ID        = 0  // the APNSetID of APNs which aren't in a set
+	MATCH_ALL_APN_SET_ID = -1 // the APNSetID which matches any preferred APN set
+)
+
+// SIM identifies a subscription.
+type SIM struct {
+	MCCMNC    string
+	IMSI      string
+	SPN       string
+	GID1      string
+	ICCID     string
+	CarrierID int // zero or negative if unknown
+}
+
+// Query is the environment to select APNs for.
+type Query struct {
+	SIM         SIM
+	NetworkType NetworkType // if unknown, APNs aren't filtered by network type
+	Roaming     bool
+
+	// PreferredAPN is the APN selected by the user, if any. It is preferred
+	// for the types it supports, and only APNs in the same set are considered
+	// (or only APNs without a set if there isn't one). It is compared by Key.
+	PreferredAPN *Setting
+}
+
+// Rejection is the reason an APN wasn't selected.
+type Rejection struct {
+	Index  int // index into the APNs
+	Reason string
+}
+
+// Selection is the APN selected for a single type.
+type Selection struct {
+	Type     Type
+	Index    int      // index into the APNs, or -1 if none was selected
+	Protocol Protocol // the protocol to use depending on the roaming state
+	Rejected []Rejection
+}
+
+// Result is the outcome of Select.
+type Result struct {
+	Candidates []int       // APNs which are usable for the SIM and environment
+	Rejected   []Rejection // APNs which are not usable for the SIM or environment
+	Selected   []Selection // for each type
+}
+
+// Select emulates how TelephonyProvider filters the APNs for a SIM, and how
+// DataProfileManager chooses the APN for each type. Ties are broken by the
+// order of apns.
+func Select(apns []Setting, q Query) Result {
+	var r Result
+
+	// TelephonyProvider: carrier_id matches take precedence over mccmnc+mvno
+	// matches, which take precedence over mccmnc-only matches
+	var (
+		byCarrierID []int
+		byMVNO      []int
+		byMCCMNC    []int
+	)
+	reason := map[int]string{}
+	for i, s := range apns {
+		switch {
+		case q.SIM.CarrierID > 0 && s.CarrierID == q.SIM.CarrierID:
+			byCarrierID = append(byCarrierID, i)
+		case s.OperatorNumeric == "" || s.OperatorNumeric != q.SIM.MCCMNC:
+			if s.CarrierID > 0 {
+				reason[i] = fmt.Sprintf("carrier id %d does not match", s.CarrierID)
+			} else {
+				reason[i] = fmt.Sprintf("mccmnc %q does not match", s.OperatorNumeric)
+			}
+		case s.MVNOType != MVNO_TYPE_UNKNOWN && s.MVNOMatchData != "":
+			if MVNOMatches(q.SIM, s.MVNOType, s.MVNOMatchData) {
+				byMVNO = append(byMVNO, i)
+			} else {
+				reason[i] = fmt.Sprintf("mvno %s %q does not match", s.MVNOType, s.MVNOMatchData)
+			}
+		default:
+			byMCCMNC = append(byMCCMNC, i)
+		}
+	}
+	var matched []int
+	switch {
+	case len(byCarrierID) != 0:
+		matched = byCarrierID
+		for _, i := range append(byMVNO, byMCCMNC...) {
+			reason[i] = "superseded by carrier id matches"
+		}
+	case len(byMVNO) != 0:
+		matched = byMVNO
+		for _, i := range byMCCMNC {
+			reason[i] = "superseded by mvno matches"
+		}
+	default:
+		matched = byMCCMNC
+	}
+
+	// DataProfileManager: filter by the environment
+	var (
+		preferred      = -1
+		preferredKey   Key
+		preferredSetID = NO_APN_SET_ID
+	)
+	if q.PreferredAPN != nil {
+		preferredKey = q.PreferredAPN.Key()
+		preferredSetID = q.PreferredAPN.APNSetID
+	}
+	for _, i := range matched {
+		s := apns[i]
+		switch {
+		case !s.CarrierEnabled:
+			reason[i] = "carrier disabled"
+		case q.NetworkType != NETWORK_TYPE_UNKNOWN && !canSupportNetworkType(s, q.NetworkType):
+			reason[i] = fmt.Sprintf("network type %s not in bitmask %s", q.NetworkType, formatChangeValue(s.NetworkTypeBitmask))
+		case s.APNSetID != MATCH_ALL_APN_SET_ID && s.APNSetID != preferredSetID:
+			reason[i] = fmt.Sprintf("apn set id %d does not match preferred apn set id %d", s.APNSetID, preferredSetID)
+		default:
+			if q.PreferredAPN != nil && preferred == -1 && s.Key() == preferredKey {
+				preferred = i
+			}
+			r.Candidates = append(r.Candidates, i)
+		}
+	}
+	for i := range apns {
+		if v, ok := reason[i]; ok {
+			r.Rejected = append(r.Rejected, Rejection{i, v})
+		}
+	}
+
+	for t := Type(1); t < type_limit; t <<= 1 {
+		handles := func(i int) bool {
+			return apns[i].Normalize().APNTypeBitmask&t != 0
+		}
+		sel := Selection{
+			Type:     t,
+			Index:    -1,
+			Protocol: PROTOCOL_UNKNOWN,
+		}
+		if preferred != -1 && handles(preferred) {
+			sel.Index = preferred
+		}
+		for _, i := range r.Candidates {
+			switch {
+			case i == sel.Index:
+			case !handles(i):
+				sel.Rejected = append(sel.Rejected, Rejection{i, fmt.Sprintf("type %s not in %s", t, apns[i].Normalize().APNTypeBitmask)})
+			case sel.Index != -1:
+				if sel.Index == preferred {
+					sel.Rejected = append(sel.Rejected, Rejection{i, "preferred apn selected"})
+				} else {
+					sel.Rejected = append(sel.Rejected, Rejection{i, fmt.Sprintf("apn %d selected first", sel.Index)})
+				}
+			default:
+				sel.Index = i
+			}
+		}
+		if sel.Index != -1 {
+			if q.Roaming {
+				sel.Protocol = apns[sel.Index].RoamingProtocol
+			} else {
+				sel.Protocol = apns[sel.Index].Protocol
+			}
+			if sel.Protocol == PROTOCOL_UNKNOWN {
+				sel.Protocol = PROTOCOL_IP // db default
+			}
+		}
+		r.Selected = append(r.Selected, sel)
+	}
+	return r
+}
+
+// canSupportNetworkType is the same as ApnSetting.canSupportNetworkType.
+func canSupportNetworkType(s Setting, t NetworkType) bool {
+	if t == NETWORK_TYPE_LTE_CA {
+		t = NETWORK_TYPE_LTE // as of Android R, LTE_CA is no longer used
+	}
+	return s.NetworkTypeBitmask == 0 || s.NetworkTypeBitmask&MakeNetworkTypeBitmask(t) != 0
+}
+
+// MVNOMatches checks whether sim matches the mvno data, like
+// ApnSettingUtils.mvnoMatches.
+func MVNOMatches(sim SIM, t MVNOType, data string) bool {
+	switch t {
+	case MVNO_TYPE_SPN:
+		return sim.SPN != "" && strings.EqualFold(sim.SPN, data)
+	case MVNO_TYPE_IMSI:
+		return sim.IMSI != "" && IMSIMatches(data, sim.IMSI)
+	case MVNO_TYPE_GID:
+		return len(sim.GID1) >= len(data) && strings.EqualFold(sim.GID1[:len(data)], data)
+	case MVNO_TYPE_ICCID:
+		if sim.ICCID != "" {
+			for _, prefix := range strings.Split(data, ",") {
+				if strings.HasPrefix(sim.ICCID, strings.TrimSpace(prefix)) {
+					return true
+				}
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// IMSIMatches checks whether imsi starts with pattern, where 'x' or 'X' in the
+// pattern match any digit.
+func IMSIMatches(pattern, imsi string) bool {
+	if len(pattern) > len(imsi) {
+		return false
+	}
+	for i := range len(pattern) {
+		if c := pattern[i]; c != 'x' && c != 'X' && c != imsi[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,219 @@
+package apn
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	mk := func(name, mccmnc string, apply func(s *Setting)) Setting {
+		s := Empty()
+		s.EntryName = name
+		s.APNName = name
+		s.OperatorNumeric = mccmnc
+		s.APNTypeBitmask = TYPE_DEFAULT
+		s.CarrierEnabled = true
+		if apply != nil {
+			apply(&s)
+		}
+		return s
+	}
+	sim := SIM{MCCMNC: "302220", SPN: "Test", GID1: "BA01", IMSI: "302220123456789", CarrierID: 1}
+
+	for _, tc := range []struct {
+		Name       string
+		APNs       []Setting
+		Query      Query
+		Candidates []string // entry names
+		Default    string   // entry name selected for TYPE_DEFAULT, or empty
+	}{
+		{
+			Name: "MCCMNC",
+			APNs: []Setting{
+				mk("a", "302220", nil),
+				mk("other", "302221", nil),
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"a"},
+			Default:    "a",
+		},
+		{
+			Name: "MVNOOverMCCMNC",
+			APNs: []Setting{
+				mk("a", "302220", nil),
+				mk("spn", "302220", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "test" }),
+				mk("gid", "302220", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_GID, "ba" }),
+				mk("imsi", "302220", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_IMSI, "30222012x" }),
+				mk("other", "302220", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "other" }),
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"spn", "gid", "imsi"},
+			Default:    "spn",
+		},
+		{
+			Name: "CarrierIDOverMVNO",
+			APNs: []Setting{
+				mk("a", "302220", nil),
+				mk("spn", "302220", func(s *Setting) { s.MVNOType, s.MVNOMatchData = MVNO_TYPE_SPN, "test" }),
+				mk("cid", "", func(s *Setting) { s.CarrierID = 1 }),
+				mk("other", "", func(s *Setting) { s.CarrierID = 2 }),
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"cid"},
+			Default:    "cid",
+		},
+		{
+			Name: "CarrierIDUnknown",
+			APNs: []Setting{
+				mk("a", "302220", nil),
+				mk("cid", "", func(s *Setting) { s.CarrierID = 1 }),
+			},
+			Query:      Query{SIM: SIM{MCCMNC: "302220"}},
+			Candidates: []string{"a"},
+			Default:    "a",
+		},
+		{
+			Name: "MVNOEmptyData",
+			APNs: []Setting{
+				mk("a", "302220", func(s *Setting) { s.MVNOType = MVNO_TYPE_SPN }),
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"a"},
+			Default:    "a",
+		},
+		{
+			Name: "Environment",
+			APNs: []Setting{
+				mk("disabled", "302220", func(s *Setting) { s.CarrierEnabled = false }),
+				mk("nr", "302220", func(s *Setting) { s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_NR) }),
+				mk("lte", "302220", func(s *Setting) { s.NetworkTypeBitmask = MakeNetworkTypeBitmask(NETWORK_TYPE_LTE) }),
+			},
+			Query:      Query{SIM: sim, NetworkType: NETWORK_TYPE_LTE_CA},
+			Candidates: []string{"lte"},
+			Default:    "lte",
+		},
+		{
+			Name: "APNSetNoPreferred",
+			APNs: []Setting{
+				mk("set1", "302220", func(s *Setting) { s.APNSetID = 1 }),
+				mk("all", "302220", func(s *Setting) { s.APNSetID = MATCH_ALL_APN_SET_ID }),
+				mk("none", "302220", nil),
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"all", "none"},
+			Default:    "all",
+		},
+		{
+			Name: "APNSetPreferred",
+			APNs: []Setting{
+				mk("none", "302220", nil),
+				mk("set1", "302220", func(s *Setting) { s.APNSetID = 1 }),
+				mk("set1 preferred", "302220", func(s *Setting) { s.APNSetID = 1 }),
+				mk("set2", "302220", func(s *Setting) { s.APNSetID = 2 }),
+				mk("all", "302220", func(s *Setting) { s.APNSetID = MATCH_ALL_APN_SET_ID }),
+			},
+			Query: Query{SIM: sim, PreferredAPN: func() *Setting {
+				s := mk("set1 preferred", "302220", func(s *Setting) { s.APNSetID = 1 })
+				return &s
+			}()},
+			Candidates: []string{"set1", "set1 preferred", "all"},
+			Default:    "set1 preferred",
+		},
+		{
+			Name: "TypeAll",
+			APNs: []Setting{
+				mk("mms", "302220", func(s *Setting) { s.APNTypeBitmask = TYPE_MMS }),
+				mk("all", "302220", func(s *Setting) { s.APNTypeBitmask = 0 }), // normalized to TYPE_ALL
+			},
+			Query:      Query{SIM: sim},
+			Candidates: []string{"mms", "all"},
+			Default:    "all",
+		},
+		{
+			Name: "None",
+			APNs: []Setting{
+				mk("other", "302221", nil),
+			},
+			Query: Query{SIM: sim},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := Select(tc.APNs, tc.Query)
+
+			var candidates []string
+			for _, i := range r.Candidates {
+				candidates = append(candidates, tc.APNs[i].EntryName)
+			}
+			if !slices.Equal(candidates, tc.Candidates) {
+				t.Errorf("expected candidates %q, got %q", tc.Candidates, candidates)
+			}
+			for i := range tc.APNs {
+				rejected := slices.ContainsFunc(r.Rejected, func(x Rejection) bool { return x.Index == i })
+				if rejected == slices.Contains(r.Candidates, i) {
+					t.Errorf("apn %q must be either a candidate or rejected", tc.APNs[i].EntryName)
+				}
+			}
+
+			var selected string
+			for _, sel := range r.Selected {
+				if sel.Type == TYPE_DEFAULT && sel.Index != -1 {
+					selected = tc.APNs[sel.Index].EntryName
+				}
+			}
+			if selected != tc.Default {
+				t.Errorf("expected %q to be selected for default, got %q", tc.Default, selected)
+			}
+		})
+	}
+}
+
+func TestSelectProtocol(t *testing.T) {
+	s := Empty()
+	s.OperatorNumeric = "302220"
+	s.CarrierEnabled = true
+	s.APNTypeBitmask = TYPE_DEFAULT
+	s.Protocol = PROTOCOL_IPV4V6
+	apns := []Setting{s}
+
+	for _, tc := range []struct {
+		Roaming bool
+		Want    Protocol
+	}{
+		{false, PROTOCOL_IPV4V6},
+		{true, PROTOCOL_IP}, // unset, so the db default
+	} {
+		r := Select(apns, Query{SIM: SIM{MCCMNC: "302220"}, Roaming: tc.Roaming})
+		for _, sel := range r.Selected {
+			if sel.Type == TYPE_DEFAULT && sel.Protocol != tc.Want {
+				t.Errorf("roaming=%t: expected protocol %s, got %s", tc.Roaming, tc.Want, sel.Protocol)
+			}
+		}
+	}
+}
+
+func TestMVNOMatches(t *testing.T) {
+	sim := SIM{SPN: "Test", GID1: "BA01FF", IMSI: "302220123456789", ICCID: "8930220000"}
+	for _, tc := range []struct {
+		Type MVNOType
+		Data string
+		Want bool
+	}{
+		{MVNO_TYPE_SPN, "test", true},
+		{MVNO_TYPE_SPN, "tes", false},
+		{MVNO_TYPE_GID, "ba01", true},
+		{MVNO_TYPE_GID, "BA01FF00", false},
+		{MVNO_TYPE_IMSI, "30222012x", true},
+		{MVNO_TYPE_IMSI, "30222013x", false},
+		{MVNO_TYPE_IMSI, "302220123456789x", false},
+		{MVNO_TYPE_ICCID, "8931, 893022", true},
+		{MVNO_TYPE_ICCID, "8931", false},
+		{MVNO_TYPE_UNKNOWN, "", false},
+	} {
+		if got := MVNOMatches(sim, tc.Type, tc.Data); got != tc.Want {
+			t.Errorf("%s %q: expected %t, got %t", tc.Type, tc.Data, tc.Want, got)
+		}
+	}
+	if MVNOMatches(SIM{}, MVNO_TYPE_SPN, "") {
+		t.Errorf("empty spn matched")
+	}
+}
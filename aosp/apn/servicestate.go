@@ -1,12 +1,12 @@
 package apn
 
 import (
-	"bytes"
 	"fmt"
 	"iter"
 	"math/bits"
 	"slices"
 	"strconv"
+	"strings"
 )
 
 // https://cs.android.com/android/platform/superproject/main/+/main:frameworks/base/telephony/java/android/telephony/ServiceState.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae
@@ -67,6 +67,79 @@ func (x RILRadioTechnology) Valid() bool {
 	return x > 0 && x < NEXT_RIL_RADIO_TECHNOLOGY
 }
 
+func (x RILRadioTechnology) String() string {
+	switch x {
+	case RIL_RADIO_TECHNOLOGY_GPRS:
+		return "GPRS"
+	case RIL_RADIO_TECHNOLOGY_EDGE:
+		return "EDGE"
+	case RIL_RADIO_TECHNOLOGY_UMTS:
+		return "UMTS"
+	case RIL_RADIO_TECHNOLOGY_IS95A:
+		return "IS95A"
+	case RIL_RADIO_TECHNOLOGY_IS95B:
+		return "IS95B"
+	case RIL_RADIO_TECHNOLOGY_1xRTT:
+		return "1xRTT"
+	case RIL_RADIO_TECHNOLOGY_EVDO_0:
+		return "EVDO_0"
+	case RIL_RADIO_TECHNOLOGY_EVDO_A:
+		return "EVDO_A"
+	case RIL_RADIO_TECHNOLOGY_HSDPA:
+		return "HSDPA"
+	case RIL_RADIO_TECHNOLOGY_HSUPA:
+		return "HSUPA"
+	case RIL_RADIO_TECHNOLOGY_HSPA:
+		return "HSPA"
+	case RIL_RADIO_TECHNOLOGY_EVDO_B:
+		return "EVDO_B"
+	case RIL_RADIO_TECHNOLOGY_EHRPD:
+		return "EHRPD"
+	case RIL_RADIO_TECHNOLOGY_LTE:
+		return "LTE"
+	case RIL_RADIO_TECHNOLOGY_HSPAP:
+		return "HSPAP"
+	case RIL_RADIO_TECHNOLOGY_GSM:
+		return "GSM"
+	case RIL_RADIO_TECHNOLOGY_TD_SCDMA:
+		return "TD_SCDMA"
+	case RIL_RADIO_TECHNOLOGY_IWLAN:
+		return "IWLAN"
+	case RIL_RADIO_TECHNOLOGY_LTE_CA:
+		return "LTE_CA"
+	case RIL_RADIO_TECHNOLOGY_NR:
+		return "NR"
+	default:
+		return ""
+	}
+}
+
+func (x RILRadioTechnology) MarshalText() ([]byte, error) {
+	if !x.Valid() {
+		return nil, fmt.Errorf("invalid bearer %d", x)
+	}
+	return []byte(x.String()), nil
+}
+
+// UnmarshalText parses a radio technology name (e.g., LTE) or number (e.g.,
+// 14).
+func (x *RILRadioTechnology) UnmarshalText(b []byte) error {
+	if v, err := strconv.ParseInt(string(b), 10, 0); err == nil {
+		if !RILRadioTechnology(v).Valid() {
+			return fmt.Errorf("invalid bearer %q", string(b))
+		}
+		*x = RILRadioTechnology(v)
+		return nil
+	}
+	for t := RIL_RADIO_TECHNOLOGY_GPRS; t < NEXT_RIL_RADIO_TECHNOLOGY; t++ {
+		if strings.EqualFold(t.String(), string(b)) {
+			*x = t
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid bearer %q", string(b))
+}
+
 func (x RILRadioTechnology) ToNetworkType() NetworkType {
 	switch x {
 	case RIL_RADIO_TECHNOLOGY_GPRS:
@@ -137,6 +210,7 @@ func (x BearerBitmask) String() string {
 
 func (x BearerBitmask) Seq() iter.Seq[RILRadioTechnology] {
 	return func(yield func(RILRadioTechnology) bool) {
+		x := x
 		for i := 0; i < bits.UintSize; i++ {
 			if x&1 != 0 {
 				if !yield(RILRadioTechnology(i + 1)) {
@@ -150,23 +224,22 @@ func (x BearerBitmask) Seq() iter.Seq[RILRadioTechnology] {
 	}
 }
 
+// UnmarshalText parses a list of radio technologies separated by '|' (the
+// format used by apns-conf.xml). See RILRadioTechnology.UnmarshalText. Like
+// ServiceState.getBitmaskFromString, "0" is the empty bitmask.
 func (x *BearerBitmask) UnmarshalText(b []byte) error {
-	var err error
-	*x = MakeBearerBitmaskSeq(func(yield func(RILRadioTechnology) bool) {
-		if len(b) != 0 {
-			for _, t := range bytes.Split(b, []byte{'|'}) {
-				v, err1 := strconv.ParseInt(string(t), 10, 0)
-				if err1 != nil || !RILRadioTechnology(v).Valid() {
-					err = fmt.Errorf("invalid bearer %q", string(t))
-					return
-				}
-				if !yield(RILRadioTechnology(v)) {
-					return
-				}
+	var v BearerBitmask
+	if s := strings.TrimSpace(string(b)); s != "" && s != "0" {
+		for _, s := range strings.Split(s, "|") {
+			var t RILRadioTechnology
+			if err := t.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+				return err
 			}
+			v |= MakeBearerBitmask(t)
 		}
-	})
-	return err
+	}
+	*x = v
+	return nil
 }
 
 func (x BearerBitmask) MarshalText() ([]byte, error) {
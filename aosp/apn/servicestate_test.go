@@ -0,0 +1,59 @@
+package apn
+
+import "testing"
+
+func TestRILRadioTechnologyText(t *testing.T) {
+	testText[RILRadioTechnology](t, []textCase[RILRadioTechnology]{
+		{"LTE", RIL_RADIO_TECHNOLOGY_LTE, "LTE"},
+		{"lte", RIL_RADIO_TECHNOLOGY_LTE, "LTE"},
+		{"14", RIL_RADIO_TECHNOLOGY_LTE, "LTE"},
+		{"is95a", RIL_RADIO_TECHNOLOGY_IS95A, "IS95A"},
+		{"4", RIL_RADIO_TECHNOLOGY_IS95A, "IS95A"},
+		{"1xrtt", RIL_RADIO_TECHNOLOGY_1xRTT, "1xRTT"},
+		{"1", RIL_RADIO_TECHNOLOGY_GPRS, "GPRS"},
+		{"20", RIL_RADIO_TECHNOLOGY_NR, "NR"},
+	}, []string{
+		"",
+		"0",
+		"21",
+		"-1",
+		"UNKNOWN",
+		"LTE ",
+	})
+	var all []RILRadioTechnology
+	for v := RIL_RADIO_TECHNOLOGY_GPRS; v < NEXT_RIL_RADIO_TECHNOLOGY; v++ {
+		all = append(all, v)
+	}
+	testRoundTrip(t, all...)
+	testMarshalError(t, RIL_RADIO_TECHNOLOGY_UNKNOWN, NEXT_RIL_RADIO_TECHNOLOGY)
+}
+
+func TestBearerBitmaskText(t *testing.T) {
+	testText[BearerBitmask](t, []textCase[BearerBitmask]{
+		{"", 0, ""},
+		{"0", 0, ""},
+		{" 0 ", 0, ""},
+		{"14", BEARER_BITMASK_LTE, "14"},
+		{"LTE", BEARER_BITMASK_LTE, "14"},
+		{"14|20", BEARER_BITMASK_LTE | BEARER_BITMASK_NR, "14|20"},
+		{"20|14", BEARER_BITMASK_LTE | BEARER_BITMASK_NR, "14|20"},
+		{"lte|nr", BEARER_BITMASK_LTE | BEARER_BITMASK_NR, "14|20"},
+		{" 14 | 20 ", BEARER_BITMASK_LTE | BEARER_BITMASK_NR, "14|20"},
+		{"14|14", BEARER_BITMASK_LTE, "14"},
+		{"IS95A|is95b", BEARER_BITMASK_IS95A | BEARER_BITMASK_IS95B, "4|5"},
+	}, []string{
+		"14,20",
+		"0|14",
+		"14|",
+		"21",
+		"foo",
+	})
+	all := []BearerBitmask{0}
+	var every BearerBitmask
+	for v := RIL_RADIO_TECHNOLOGY_GPRS; v < NEXT_RIL_RADIO_TECHNOLOGY; v++ {
+		all = append(all, MakeBearerBitmask(v))
+		every |= MakeBearerBitmask(v)
+	}
+	testRoundTrip(t, append(all, every)...)
+	testMarshalError(t, BEARER_BITMASK_NR<<1)
+}
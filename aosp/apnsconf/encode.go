@@ -0,0 +1,147 @@
+package apnsconf
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+	"github.com/pgaskin/xmlwriter"
+)
+
+// EntryError is an error for a single entry passed to Encoder.Encode.
+type EntryError struct {
+	Index int // zero-based index of the Encode call
+	Group string
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	if e.Group != "" {
+		return fmt.Sprintf("entry %d (%s): %v", e.Index, e.Group, e.Err)
+	}
+	return fmt.Sprintf("entry %d: %v", e.Index, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Encoder writes an apns-conf.xml document. Output is flushed after each
+// element is written.
+type Encoder struct {
+	// Version is the value of the version attribute on the root element. If
+	// zero, the Version constant is used.
+	Version int
+
+	// Header is an optional comment to write before the root element.
+	Header string
+
+	// Options controls how entries are converted to attributes.
+	Options Options
+
+	w       *xmlwriter.XMLWriter
+	started bool
+	group   string
+	n       int
+	errs    []error
+}
+
+// NewEncoder returns a new Encoder writing to w. The header is written on the
+// first call to Encode or Close, so the exported fields can be set before then.
+func NewEncoder(w io.Writer) *Encoder {
+	x := xmlwriter.New(w)
+	x.Indent("  ")
+	return &Encoder{w: x}
+}
+
+func (e *Encoder) start() error {
+	if !e.started {
+		e.started = true
+		version := e.Version
+		if version == 0 {
+			version = Version
+		}
+		e.w.DefaultProcInst()
+		if e.Header != "" {
+			e.w.Comment(true, " "+e.Header+" ")
+		}
+		e.w.Start(nil, "apns", xmlwriter.NS("").Bind(""))
+		e.w.Attr(nil, "version", strconv.Itoa(version))
+	}
+	return e.w.Err()
+}
+
+// Encode writes s. Consecutive entries with the same non-empty group are
+// written under a single comment. If s cannot be encoded, it is skipped, an
+// *EntryError is returned and recorded, and encoding can continue. Write errors
+// are sticky.
+func (e *Encoder) Encode(group string, s apn.Setting) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	defer func() { e.n++ }()
+
+	var (
+		err   error
+		attrs []string
+	)
+	for k, v := range e.Options.XMLAttrSeq(s, &err) {
+		attrs = append(attrs, k, v)
+	}
+	if err != nil {
+		err = &EntryError{Index: e.n, Group: group, Err: err}
+		e.errs = append(e.errs, err)
+		return err
+	}
+
+	if group != e.group {
+		e.group = group
+		if group != "" {
+			e.w.BlankLine()
+			e.w.Comment(true, " "+group+" ")
+		}
+	}
+	e.w.Start(nil, "apn")
+	for kv := range slices.Chunk(attrs, 2) {
+		e.w.Attr(nil, kv[0], kv[1])
+	}
+	return e.w.End(true)
+}
+
+// Errors returns the errors for the entries which were skipped.
+func (e *Encoder) Errors() []error {
+	return e.errs
+}
+
+// Close ends the document. It does not close the underlying writer. It returns
+// write errors, but not errors from skipped entries.
+func (e *Encoder) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	e.w.End(false)
+	return e.w.Close()
+}
+
+// GroupByEntryName groups entries by the carrier (i.e., the display name).
+func GroupByEntryName(s apn.Setting) string {
+	return s.EntryName
+}
+
+// GroupByCountry groups entries by the MCC, which identifies the country.
+func GroupByCountry(s apn.Setting) string {
+	if len(s.OperatorNumeric) < 3 || s.OperatorNumeric == "000000" {
+		return ""
+	}
+	return "mcc " + s.OperatorNumeric[:3]
+}
+
+// GroupByCarrierID groups entries by the carrier id, if set.
+func GroupByCarrierID(s apn.Setting) string {
+	if s.CarrierID <= 0 {
+		return ""
+	}
+	return "carrier_id " + strconv.Itoa(s.CarrierID)
+}
@@ -0,0 +1,109 @@
+package apnsconf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+)
+
+func TestEncoder(t *testing.T) {
+	setting := func(name, mccmnc string, carrierID int) apn.Setting {
+		s := apn.Empty()
+		s.EntryName = name
+		s.APNName = strings.ToLower(name)
+		s.OperatorNumeric = mccmnc
+		s.CarrierID = carrierID
+		s.CarrierEnabled = true
+		return s
+	}
+	apns := []apn.Setting{
+		setting("A", "302220", 1),
+		setting("A", "302220", 1),
+		setting("B", "302221", 0),
+		setting("C", "310260", 2),
+		setting("A", "302220", 1),
+	}
+
+	for _, tc := range []struct {
+		Name   string
+		Group  func(apn.Setting) string
+		Groups []string // comments, in order
+	}{
+		{"None", func(apn.Setting) string { return "" }, nil},
+		{"EntryName", GroupByEntryName, []string{"A", "B", "C", "A"}},
+		{"Country", GroupByCountry, []string{"mcc 302", "mcc 310", "mcc 302"}},
+		{"CarrierID", GroupByCarrierID, []string{"carrier_id 1", "carrier_id 2", "carrier_id 1"}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var b strings.Builder
+			e := NewEncoder(&b)
+			e.Version = 8
+			e.Header = "header"
+			for _, s := range apns {
+				if err := e.Encode(tc.Group(s), s); err != nil {
+					t.Fatalf("encode: unexpected error: %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("close: unexpected error: %v", err)
+			}
+			out := b.String()
+
+			if !strings.Contains(out, "<!-- header -->") || !strings.Contains(out, `<apns version="8">`) {
+				t.Errorf("missing header or version:\n%s", out)
+			}
+			if n := strings.Count(out, "<apn "); n != len(apns) {
+				t.Errorf("expected %d apns, got %d:\n%s", len(apns), n, out)
+			}
+			var groups []string
+			for _, line := range strings.Split(out, "\n") {
+				if line = strings.TrimSpace(line); strings.HasPrefix(line, "<!-- ") && line != "<!-- header -->" {
+					groups = append(groups, strings.TrimSuffix(strings.TrimPrefix(line, "<!-- "), " -->"))
+				}
+			}
+			if strings.Join(groups, ";") != strings.Join(tc.Groups, ";") {
+				t.Errorf("expected groups %q, got %q:\n%s", tc.Groups, groups, out)
+			}
+		})
+	}
+}
+
+func TestEncoderEntryError(t *testing.T) {
+	var b strings.Builder
+	e := NewEncoder(&b)
+	e.Options.TargetSDK = 28
+
+	valid := apn.Empty()
+	valid.EntryName = "Valid"
+	valid.OperatorNumeric = "302220"
+
+	invalid := valid
+	invalid.EntryName = "Invalid"
+	invalid.APNTypeBitmask = apn.TYPE_ENTERPRISE // requires a newer api level
+
+	if err := e.Encode("a", valid); err != nil {
+		t.Fatalf("encode: unexpected error: %v", err)
+	}
+	var ee *EntryError
+	if err := e.Encode("b", invalid); !errors.As(err, &ee) || ee.Index != 1 || ee.Group != "b" {
+		t.Errorf("expected an entry error for index 1 in group b, got %v", err)
+	}
+	if err := e.Encode("a", valid); err != nil {
+		t.Fatalf("encode: unexpected error: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("close: unexpected error: %v", err)
+	}
+	if n := len(e.Errors()); n != 1 {
+		t.Errorf("expected 1 recorded error, got %d", n)
+	}
+	out := b.String()
+	if strings.Contains(out, "Invalid") || strings.Contains(out, "<!-- b -->") {
+		t.Errorf("skipped entry was written:\n%s", out)
+	}
+	if n := strings.Count(out, "<!-- a -->"); n != 1 {
+		t.Errorf("expected the group to continue after the skipped entry, got %d comments:\n%s", n, out)
+	}
+}
@@ -0,0 +1,340 @@
+package apnsconf
+
+import (
+	"encoding"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+)
+
+// ErrUnknownAttr is returned (wrapped) for attributes not read by TelephonyProvider.
+var ErrUnknownAttr = errors.New("unknown attribute")
+
+// ElementError is returned by the Decoder for an invalid element. Decoding can
+// continue after it is returned.
+type ElementError struct {
+	Line int
+	Err  error
+}
+
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads apn elements from an apns-conf.xml document.
+type Decoder struct {
+	d       *xml.Decoder
+	root    bool
+	version int
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{d: xml.NewDecoder(r)}
+}
+
+// Version reads the root element if it hasn't been read yet, and returns the
+// version attribute from it, or zero if it isn't set.
+func (d *Decoder) Version() (int, error) {
+	if !d.root {
+		for {
+			tok, err := d.d.Token()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+			if el, ok := tok.(xml.StartElement); ok {
+				if el.Name.Local != "apns" {
+					line, _ := d.d.InputPos()
+					return 0, &ElementError{line, fmt.Errorf("expected root element apns, got %q", el.Name.Local)}
+				}
+				for _, a := range el.Attr {
+					if a.Name.Local == "version" {
+						v, err := strconv.Atoi(a.Value)
+						if err != nil {
+							line, _ := d.d.InputPos()
+							return 0, &ElementError{line, fmt.Errorf("invalid version %q", a.Value)}
+						}
+						d.version = v
+					}
+				}
+				break
+			}
+		}
+		d.root = true
+	}
+	return d.version, nil
+}
+
+// Decode reads the next apn element. At the end of the document, io.EOF is
+// returned. If the element is invalid, an *ElementError is returned along with
+// the best-effort result, and decoding can continue.
+func (d *Decoder) Decode() (apn.Setting, error) {
+	if _, err := d.Version(); err != nil {
+		return apn.Setting{}, err
+	}
+	for {
+		line, _ := d.d.InputPos() // the start of the next token
+		tok, err := d.d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return apn.Setting{}, err
+		}
+		switch tok := tok.(type) {
+		case xml.EndElement:
+			return apn.Setting{}, io.EOF // end of root
+		case xml.StartElement:
+			if err := d.d.Skip(); err != nil {
+				return apn.Setting{}, err
+			}
+			if tok.Name.Local != "apn" {
+				return apn.Setting{}, &ElementError{line, fmt.Errorf("unknown element %q", tok.Name.Local)}
+			}
+			s, err := ParseXMLAttrs(func(yield func(string, string) bool) {
+				for _, a := range tok.Attr {
+					if !yield(a.Name.Local, a.Value) {
+						return
+					}
+				}
+			})
+			if err != nil {
+				return s, &ElementError{line, err}
+			}
+			return s, nil
+		}
+	}
+}
+
+// Read reads all apn elements from r. Invalid elements are skipped, and their
+// errors are joined and returned along with the valid ones.
+func Read(r io.Reader) (version int, apns []apn.Setting, err error) {
+	d := NewDecoder(r)
+	if version, err = d.Version(); err != nil {
+		return 0, nil, err
+	}
+	var errs []error
+	for {
+		s, err := d.Decode()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			if _, ok := err.(*ElementError); ok {
+				errs = append(errs, err)
+				continue
+			}
+			return version, apns, err
+		}
+		apns = append(apns, s)
+	}
+	return version, apns, errors.Join(errs...)
+}
+
+// ParseXMLAttrs is the inverse of XMLAttrSeq. It applies the same defaults as
+// TelephonyProvider (getRow) and ApnSetting (makeApnSetting). All invalid
+// attributes are returned as a joined error, and the remaining ones are still
+// applied.
+func ParseXMLAttrs(seq iter.Seq2[string, string]) (apn.Setting, error) {
+	var (
+		errs  []error
+		attrs = map[string]string{}
+	)
+	for k, v := range seq {
+		if _, ok := attrs[k]; ok {
+			errs = append(errs, fmt.Errorf("duplicate attribute %q", k))
+		}
+		attrs[k] = v
+	}
+
+	s := apn.Empty()
+	s.CarrierEnabled = true             // db default
+	s.Protocol = apn.PROTOCOL_IP        // db default
+	s.RoamingProtocol = apn.PROTOCOL_IP // db default
+
+	attr := func(k string) (string, bool) {
+		v, ok := attrs[k]
+		delete(attrs, k)
+		return v, ok
+	}
+	str := func(k string, p *string) {
+		if v, ok := attr(k); ok {
+			*p = v
+		}
+	}
+	num := func(k string, p *int) {
+		if v, ok := attr(k); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s %q", k, v))
+			} else {
+				*p = n
+			}
+		}
+	}
+	port := func(k string, p *int) {
+		if v, ok := attr(k); ok && v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n < -1 || n > 0xFFFF {
+				errs = append(errs, fmt.Errorf("invalid %s %q", k, v))
+			} else {
+				*p = n
+			}
+		}
+	}
+	boolean := func(k string, p *bool) {
+		if v, ok := attr(k); ok {
+			switch {
+			case strings.EqualFold(v, "true"):
+				*p = true
+			case strings.EqualFold(v, "false"):
+				*p = false
+			default:
+				errs = append(errs, fmt.Errorf("invalid %s %q", k, v))
+			}
+		}
+	}
+	text := func(k string, p encoding.TextUnmarshaler) {
+		if v, ok := attr(k); ok {
+			if err := p.UnmarshalText([]byte(v)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s: %w", k, err))
+			}
+		}
+	}
+
+	mcc, hasMCC := attr("mcc")
+	mnc, hasMNC := attr("mnc")
+	switch {
+	case hasMCC && hasMNC:
+		s.OperatorNumeric = mcc + mnc
+		if n := len(s.OperatorNumeric); n != 5 && n != 6 {
+			errs = append(errs, fmt.Errorf("invalid operator mccmnc length %d", n))
+		}
+	case hasMCC || hasMNC:
+		errs = append(errs, fmt.Errorf("mcc and mnc must be set together"))
+	}
+
+	if v, ok := attr("carrier"); ok && v != "" {
+		s.EntryName = v
+	} else {
+		errs = append(errs, fmt.Errorf("entry name is required"))
+	}
+	str("apn", &s.APNName)
+	str("user", &s.User)
+	str("server", &s.Server)
+	str("password", &s.Password)
+	str("proxy", &s.ProxyAddress)
+	port("port", &s.ProxyPort)
+	str("mmsproxy", &s.MMSProxyAddress)
+	port("mmsport", &s.MMSProxyPort)
+	str("mmsc", &s.MMSC)
+
+	// ApnSetting.getApnTypesBitmaskFromString: unset or empty means all, unknown types are skipped, case-insensitive, whitespace is removed by getRow
+	if v, ok := attr("type"); !ok || len(strings.Fields(v)) == 0 {
+		s.APNTypeBitmask = apn.TYPE_ALL
+	} else {
+		for _, v := range strings.Split(v, ",") {
+			var t apn.Type
+			if err := t.UnmarshalText([]byte(v)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid type: %w", err))
+			} else {
+				s.APNTypeBitmask |= t
+			}
+		}
+	}
+
+	text("protocol", &s.Protocol)
+	text("roaming_protocol", &s.RoamingProtocol)
+
+	var authType = int(s.AuthType)
+	if num("authtype", &authType); authType < int(apn.AUTH_TYPE_UNKNOWN) || authType > int(apn.AUTH_TYPE_PAP_OR_CHAP) {
+		errs = append(errs, fmt.Errorf("invalid authtype %d", authType))
+	} else {
+		s.AuthType = apn.AuthType(authType)
+	}
+
+	num("profile_id", &s.ProfileID)
+	num("max_conns", &s.MaxConns)
+	num("wait_time", &s.WaitTime)
+	num("max_conns_time", &s.MaxConnsTime)
+
+	// ApnSetting.makeApnSetting: mtu_v4 falls back to the deprecated mtu
+	var mtu int
+	num("mtu", &mtu)
+	num("mtu_v4", &s.MTUv4)
+	num("mtu_v6", &s.MTUv6)
+	if s.MTUv4 <= 0 {
+		s.MTUv4 = mtu
+	}
+
+	num("apn_set_id", &s.APNSetID)
+	num("carrier_id", &s.CarrierID)
+
+	var skip464XLAT = int(s.Skip464XLAT)
+	if num("skip_464xlat", &skip464XLAT); skip464XLAT < int(apn.SKIP_464XLAT_DEFAULT) || skip464XLAT > int(apn.SKIP_464XLAT_ENABLE) {
+		errs = append(errs, fmt.Errorf("invalid skip_464xlat %d", skip464XLAT))
+	} else {
+		s.Skip464XLAT = apn.Skip464XLAT(skip464XLAT)
+	}
+
+	boolean("carrier_enabled", &s.CarrierEnabled)
+	boolean("modem_cognitive", &s.Persistent)
+	boolean("user_visible", &s.UserVisible)
+	boolean("user_editable", &s.UserEditable)
+	boolean("always_on", &s.AlwaysOn)
+	boolean("esim_bootstrap_provisioning", &s.ESIMBootstrapProvisioning)
+
+	text("infrastructure_bitmask", &s.InfrastructureBitmask)
+
+	text("lingering_network_type_bitmask", &s.LingeringNetworkTypeBitmask)
+
+	// getRow: network_type_bitmask takes precedence over bearer_bitmask, and
+	// the legacy single bearer is merged into the bearer bitmask
+	var bearer int
+	num("bearer", &bearer)
+	text("bearer_bitmask", &s.BearerBitmask)
+	if bearer != 0 {
+		if !apn.RILRadioTechnology(bearer).Valid() {
+			errs = append(errs, fmt.Errorf("invalid bearer %d", bearer))
+		} else {
+			s.BearerBitmask |= apn.MakeBearerBitmask(apn.RILRadioTechnology(bearer))
+		}
+	}
+	if v, ok := attr("network_type_bitmask"); ok {
+		if err := s.NetworkTypeBitmask.UnmarshalText([]byte(v)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid network_type_bitmask: %w", err))
+		}
+		s.BearerBitmask = 0 // derived from the network type bitmask by getRow, so don't preserve it
+	} else {
+		s.NetworkTypeBitmask = apn.ConvertBearerBitmaskToNetworkTypeBitmask(s.BearerBitmask)
+	}
+
+	// getRow: mvno_type is ignored without mvno_match_data
+	mvnoType, hasMVNOType := attr("mvno_type")
+	mvnoMatchData, hasMVNOMatchData := attr("mvno_match_data")
+	if hasMVNOType && hasMVNOMatchData {
+		if err := s.MVNOType.UnmarshalText([]byte(mvnoType)); err != nil {
+			errs = append(errs, fmt.Errorf("invalid mvno_type: %w", err))
+		} else {
+			s.MVNOMatchData = mvnoMatchData
+		}
+	}
+
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		errs = append(errs, fmt.Errorf("%w %q", ErrUnknownAttr, k))
+	}
+	return s, errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package apnsconf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+)
+
+func TestParseXMLAttrs(t *testing.T) {
+	base := apn.Empty()
+	base.EntryName = "Test"
+	base.CarrierEnabled = true
+	base.Protocol = apn.PROTOCOL_IP
+	base.RoamingProtocol = apn.PROTOCOL_IP
+	base.APNTypeBitmask = apn.TYPE_ALL
+
+	for _, tc := range []struct {
+		Name  string
+		Attrs []string // key, value
+		Apply func(s *apn.Setting)
+		Error bool
+	}{
+		{"Defaults", nil, func(s *apn.Setting) {}, false},
+		{"EntryNameRequired", []string{"carrier", ""}, func(s *apn.Setting) { s.EntryName = "" }, true},
+		{"Operator", []string{"mcc", "302", "mnc", "220"}, func(s *apn.Setting) { s.OperatorNumeric = "302220" }, false},
+		{"OperatorMCCOnly", []string{"mcc", "302"}, func(s *apn.Setting) {}, true},
+		{"TypeEmpty", []string{"type", " "}, func(s *apn.Setting) {}, false},
+		{"Type", []string{"type", "default,MMS"}, func(s *apn.Setting) { s.APNTypeBitmask = apn.TYPE_DEFAULT | apn.TYPE_MMS }, false},
+		{"TypeUnknown", []string{"type", "default,bogus"}, func(s *apn.Setting) { s.APNTypeBitmask = apn.TYPE_DEFAULT }, true},
+		{"MTU", []string{"mtu", "1400"}, func(s *apn.Setting) { s.MTUv4 = 1400 }, false},
+		{"MTUv4", []string{"mtu", "1400", "mtu_v4", "1300"}, func(s *apn.Setting) { s.MTUv4 = 1300 }, false},
+		{"MTUv4Zero", []string{"mtu", "1400", "mtu_v4", "0", "mtu_v6", "1280"}, func(s *apn.Setting) { s.MTUv4, s.MTUv6 = 1400, 1280 }, false},
+		{"Bearer", []string{"bearer", "14"}, func(s *apn.Setting) {
+			s.BearerBitmask = apn.MakeBearerBitmask(apn.RIL_RADIO_TECHNOLOGY_LTE)
+			s.NetworkTypeBitmask = apn.MakeNetworkTypeBitmask(apn.NETWORK_TYPE_LTE)
+		}, false},
+		{"BearerMerged", []string{"bearer", "14", "bearer_bitmask", "3"}, func(s *apn.Setting) {
+			s.BearerBitmask = apn.MakeBearerBitmask(apn.RIL_RADIO_TECHNOLOGY_LTE, apn.RIL_RADIO_TECHNOLOGY_UMTS)
+			s.NetworkTypeBitmask = apn.MakeNetworkTypeBitmask(apn.NETWORK_TYPE_LTE, apn.NETWORK_TYPE_UMTS)
+		}, false},
+		{"BearerInvalid", []string{"bearer", "99"}, func(s *apn.Setting) {}, true},
+		{"NetworkTypeBitmask", []string{"bearer", "14", "network_type_bitmask", "20"}, func(s *apn.Setting) {
+			s.NetworkTypeBitmask = apn.MakeNetworkTypeBitmask(apn.NETWORK_TYPE_NR)
+		}, false},
+		{"MVNO", []string{"mvno_type", "gid", "mvno_match_data", "BA"}, func(s *apn.Setting) { s.MVNOType, s.MVNOMatchData = apn.MVNO_TYPE_GID, "BA" }, false},
+		{"MVNOTypeWithoutData", []string{"mvno_type", "spn"}, func(s *apn.Setting) {}, false},
+		{"MVNODataWithoutType", []string{"mvno_match_data", "x"}, func(s *apn.Setting) {}, false},
+		{"MVNOTypeInvalid", []string{"mvno_type", "bogus", "mvno_match_data", "x"}, func(s *apn.Setting) {}, true},
+		{"Ports", []string{"port", "", "mmsport", "80"}, func(s *apn.Setting) { s.MMSProxyPort = 80 }, false},
+		{"PortInvalid", []string{"port", "65536"}, func(s *apn.Setting) {}, true},
+		{"Booleans", []string{"carrier_enabled", "FALSE", "user_visible", "false", "modem_cognitive", "true"}, func(s *apn.Setting) {
+			s.CarrierEnabled, s.UserVisible, s.Persistent = false, false, true
+		}, false},
+		{"BooleanInvalid", []string{"always_on", "1"}, func(s *apn.Setting) {}, true},
+		{"AuthType", []string{"authtype", "0"}, func(s *apn.Setting) { s.AuthType = apn.AUTH_TYPE_NONE }, false},
+		{"AuthTypeInvalid", []string{"authtype", "4"}, func(s *apn.Setting) {}, true},
+		{"Protocol", []string{"protocol", "IPV4V6", "roaming_protocol", "IPV6"}, func(s *apn.Setting) {
+			s.Protocol, s.RoamingProtocol = apn.PROTOCOL_IPV4V6, apn.PROTOCOL_IPV6
+		}, false},
+		{"Duplicate", []string{"apn", "a", "apn", "b"}, func(s *apn.Setting) { s.APNName = "b" }, true},
+		{"Unknown", []string{"bogus", "1", "apn", "test"}, func(s *apn.Setting) { s.APNName = "test" }, true},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			want := base
+			tc.Apply(&want)
+
+			attrs := append([]string{"carrier", "Test"}, tc.Attrs...)
+			s, err := ParseXMLAttrs(func(yield func(string, string) bool) {
+				for i := 0; i < len(attrs); i += 2 {
+					if !yield(attrs[i], attrs[i+1]) {
+						return
+					}
+				}
+			})
+			if tc.Error != (err != nil) {
+				t.Errorf("expected error=%t, got %v", tc.Error, err)
+			}
+			if s != want {
+				t.Errorf("incorrect result\nexpected %+v\n     got %+v", want, s)
+			}
+		})
+	}
+
+	t.Run("UnknownAttr", func(t *testing.T) {
+		_, err := ParseXMLAttrs(func(yield func(string, string) bool) {
+			_ = yield("carrier", "Test") && yield("bogus", "1")
+		})
+		if !errors.Is(err, ErrUnknownAttr) {
+			t.Errorf("expected ErrUnknownAttr, got %v", err)
+		}
+	})
+}
+
+func TestRead(t *testing.T) {
+	version, apns, err := Read(strings.NewReader(`<?xml version="1.0" encoding="utf-8"?>
+<apns version="8">
+  <apn carrier="A" mcc="302" mnc="220" apn="a" />
+  <!-- comment -->
+  <apn carrier="B" mcc="302" mnc="220" apn="b" bogus="1" />
+  <other />
+  <apn carrier="C" mcc="302" mnc="221" apn="c" />
+</apns>
+`))
+	if version != 8 {
+		t.Errorf("expected version 8, got %d", version)
+	}
+	var names []string
+	for _, s := range apns {
+		names = append(names, s.EntryName)
+	}
+	if want := "A,C"; strings.Join(names, ",") != want {
+		t.Errorf("expected entries %s, got %s", want, strings.Join(names, ","))
+	}
+	var e *ElementError
+	if !errors.As(err, &e) || e.Line != 5 {
+		t.Errorf("expected an element error on line 5, got %v", err)
+	}
+}
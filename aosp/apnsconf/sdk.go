@@ -0,0 +1,101 @@
+package apnsconf
+
+import (
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+)
+
+// https://cs.android.com/android/platform/superproject/main/+/main:packages/providers/TelephonyProvider/src/com/android/providers/telephony/TelephonyProvider.java (history of getRow)
+// https://developer.android.com/reference/android/telephony/data/ApnSetting (added in API level)
+
+// Options controls how settings are converted to attributes.
+type Options struct {
+	// TargetSDK is the API level of the devices the output is for. Attributes
+	// which aren't read by TelephonyProvider at that level are dropped, the
+	// deprecated or replacement form of an attribute is chosen accordingly, and
+	// values which can't be represented cause an error. If zero, attributes
+	// understood by the latest version are emitted, and the older form is
+	// preferred where it is lossless.
+	TargetSDK int
+}
+
+// attrSDK is the API level each attribute was first read at by
+// TelephonyProvider. Attributes which aren't listed were always read.
+var attrSDK = map[string]int{
+	"bearer_bitmask":                 23,
+	"profile_id":                     23,
+	"modem_cognitive":                23,
+	"max_conns":                      23,
+	"wait_time":                      23,
+	"max_conns_time":                 23,
+	"mtu":                            23,
+	"user_visible":                   24,
+	"user_editable":                  26,
+	"network_type_bitmask":           28,
+	"apn_set_id":                     28,
+	"carrier_id":                     29,
+	"skip_464xlat":                   29,
+	"always_on":                      31,
+	"mtu_v4":                         33,
+	"mtu_v6":                         33,
+	"lingering_network_type_bitmask": 33,
+	"infrastructure_bitmask":         35,
+	"esim_bootstrap_provisioning":    35,
+}
+
+// typeSDK returns the API level t was added at.
+func typeSDK(t apn.Type) int {
+	switch t {
+	case apn.TYPE_IA:
+		return 21
+	case apn.TYPE_EMERGENCY:
+		return 26
+	case apn.TYPE_MCX:
+		return 29
+	case apn.TYPE_XCAP:
+		return 30
+	case apn.TYPE_VSIM, apn.TYPE_BIP:
+		return 31
+	case apn.TYPE_ENTERPRISE:
+		return 33
+	case apn.TYPE_RCS:
+		return 34
+	default:
+		return 0
+	}
+}
+
+// protocolSDK returns the API level p was added at.
+func protocolSDK(p apn.Protocol) int {
+	switch p {
+	case apn.PROTOCOL_NON_IP, apn.PROTOCOL_UNSTRUCTURED:
+		return 29
+	default:
+		return 0
+	}
+}
+
+// networkTypeSDK returns the API level t was added at.
+func networkTypeSDK(t apn.NetworkType) int {
+	switch t {
+	case apn.NETWORK_TYPE_NR:
+		return 29
+	default:
+		return 0
+	}
+}
+
+// supports returns true if an attribute, type, or value added in sdk can be
+// used.
+func (o Options) supports(sdk int) bool {
+	return o.TargetSDK == 0 || o.TargetSDK >= sdk
+}
+
+// filterAttrs wraps yield to drop attributes which aren't supported.
+func (o Options) filterAttrs(yield func(string, string) bool) func(string, string) bool {
+	return func(k, v string) bool {
+		if !o.supports(attrSDK[k]) {
+			return true
+		}
+		return yield(k, v)
+	}
+}
@@ -8,8 +8,6 @@ import (
 	"github.com/pgaskin/apn-extract-utils/aosp/apn"
 )
 
-// TODO: target/min sdk, version? for checking attrs and deciding whether to output deprecated or new ones
-
 const Version = 8
 
 // https://cs.android.com/android/platform/superproject/main/+/main:packages/providers/TelephonyProvider/src/com/android/providers/telephony/TelephonyProvider.java;l=2716;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae (getRow)
@@ -18,8 +16,16 @@ const Version = 8
 // https://cs.android.com/android/platform/superproject/main/+/main:frameworks/base/core/java/android/provider/Telephony.java;l=3108;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae
 // https://github.com/LineageOS/android_vendor_lineage/blob/56ec683ee675eefa2fb618c06e8e29d47f2fffdb/tools/apns-conf.xsd (for confirmation)
 
+// XMLAttrSeq is like Options.XMLAttrSeq with the default options.
 func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
+	return Options{}.XMLAttrSeq(s, err)
+}
+
+// XMLAttrSeq returns the apns-conf.xml attributes for s. If s cannot be
+// represented, err is set after iteration is complete.
+func (o Options) XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 	return func(yield func(string, string) bool) {
+		yield = o.filterAttrs(yield)
 		*err = func() error {
 			// mcc/mnc/mvno_type/mvno_match_data will be replaced entirely with carrier_id matching in the future
 			if s.OperatorNumeric != "" && s.OperatorNumeric != "000000" {
@@ -90,6 +96,11 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 				}
 			}
 			if v := s.APNTypeBitmask; v != 0 {
+				for t := range v.Seq() {
+					if sdk := typeSDK(t); !o.supports(sdk) {
+						return fmt.Errorf("apn type %s requires api %d (target %d)", t, sdk, o.TargetSDK)
+					}
+				}
 				if b, err := v.MarshalText(); err != nil {
 					return fmt.Errorf("invalid apn type bitmask: %w", err)
 				} else if !yield("type", string(b)) {
@@ -97,6 +108,9 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 				}
 			}
 			if v := s.Protocol; v != apn.PROTOCOL_UNKNOWN && v != apn.PROTOCOL_IP { // IP is assumed to be the default and left out in the official xml files
+				if sdk := protocolSDK(v); !o.supports(sdk) {
+					return fmt.Errorf("protocol %s requires api %d (target %d)", v, sdk, o.TargetSDK)
+				}
 				if b, err := v.MarshalText(); err != nil {
 					return fmt.Errorf("invalid protocol: %w", err)
 				} else if !yield("protocol", string(b)) {
@@ -104,6 +118,9 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 				}
 			}
 			if v := s.RoamingProtocol; v != apn.PROTOCOL_UNKNOWN && v != apn.PROTOCOL_IP { // IP is assumed to be the default and left out in the official xml files
+				if sdk := protocolSDK(v); !o.supports(sdk) {
+					return fmt.Errorf("roaming protocol %s requires api %d (target %d)", v, sdk, o.TargetSDK)
+				}
 				if b, err := v.MarshalText(); err != nil {
 					return fmt.Errorf("invalid roaming protocol: %w", err)
 				} else if !yield("roaming_protocol", string(b)) {
@@ -136,12 +153,27 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 					return nil
 				}
 			}
-			if s.MTUv6 <= 0 && s.MTUv4 > 0 {
+			switch {
+			case o.TargetSDK != 0 && o.TargetSDK < 33:
+				// mtu_v4 and mtu_v6 aren't supported, so use the single mtu for both
+				if s.MTUv4 > 0 && s.MTUv6 > 0 && s.MTUv4 != s.MTUv6 {
+					return fmt.Errorf("different mtu v4 %d and v6 %d require api 33 (target %d)", s.MTUv4, s.MTUv6, o.TargetSDK)
+				}
+				if v := s.MTUv4; v > 0 {
+					if !yield("mtu", strconv.Itoa(v)) {
+						return nil
+					}
+				} else if v := s.MTUv6; v > 0 {
+					if !yield("mtu", strconv.Itoa(v)) {
+						return nil
+					}
+				}
+			case o.TargetSDK == 0 && s.MTUv6 <= 0 && s.MTUv4 > 0:
 				// note: mtu is deprecated, replaced with mtu_v4 in sdk 33
 				if !yield("mtu", strconv.Itoa(s.MTUv4)) {
 					return nil
 				}
-			} else {
+			default:
 				if v := s.MTUv4; v > 0 {
 					if !yield("mtu_v4", strconv.Itoa(v)) {
 						return nil
@@ -159,6 +191,9 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 				}
 			}
 			if v := s.CarrierID; v != 0 {
+				if sdk := attrSDK["carrier_id"]; !o.supports(sdk) && (s.OperatorNumeric == "" || s.OperatorNumeric == "000000") {
+					return fmt.Errorf("carrier id without a mccmnc requires api %d (target %d)", sdk, o.TargetSDK)
+				}
 				if !yield("carrier_id", strconv.Itoa(v)) {
 					return nil
 				}
@@ -198,7 +233,7 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 					return nil
 				}
 			}
-			if v := s.InfrastructureBitmask; v != 0 { // don't include if not explicitly set
+			if v := s.InfrastructureBitmask; v != 0 && v != apn.INFRASTRUCTURE_CELLULAR|apn.INFRASTRUCTURE_SATELLITE { // don't include if not explicitly set or the default
 				if b, err := v.MarshalText(); err != nil {
 					return fmt.Errorf("invalid infrastructure bitmask: %w", err)
 				} else if !yield("infrastructure_bitmask", string(b)) {
@@ -209,23 +244,48 @@ func XMLAttrSeq(s apn.Setting, err *error) iter.Seq2[string, string] {
 				if !s.NetworkTypeBitmask.Valid() {
 					return fmt.Errorf("invalid network type bitmask")
 				}
+				for t := range s.NetworkTypeBitmask.Seq() {
+					if sdk := networkTypeSDK(t); !o.supports(sdk) {
+						return fmt.Errorf("network type %s requires api %d (target %d)", t, sdk, o.TargetSDK)
+					}
+				}
 				bearerBitmask := apn.ConvertNetworkTypeBitmaskToBearerBitmask(s.NetworkTypeBitmask)
 				bearerBitmaskBack := apn.ConvertBearerBitmaskToNetworkTypeBitmask(bearerBitmask)
-				// if not present, ApnSetting.makeApnSetting will create the network_type_bitmask from the bearer_bitmask
-				// in newer versions of android, the sample apns-conf.xml only includes network_type_bitmask, but we'll prefer using the bearer_bitmask for compatibility if it effectively equals the network bitmask
-				if bearerBitmaskBack != s.NetworkTypeBitmask {
-					if v := s.NetworkTypeBitmask; v != 0 {
-						if b, err := v.MarshalText(); err != nil {
+				switch {
+				case o.TargetSDK == 0:
+					// if not present, ApnSetting.makeApnSetting will create the network_type_bitmask from the bearer_bitmask
+					// in newer versions of android, the sample apns-conf.xml only includes network_type_bitmask, but we'll prefer using the bearer_bitmask for compatibility if it effectively equals the network bitmask
+					if bearerBitmaskBack != s.NetworkTypeBitmask {
+						if b, err := s.NetworkTypeBitmask.MarshalText(); err != nil {
 							return fmt.Errorf("invalid network type bitmask: %w", err)
 						} else if !yield("network_type_bitmask", string(b)) {
 							return nil
 						}
 					}
-				}
-				if v := bearerBitmask; v != 0 {
-					if b, err := v.MarshalText(); err != nil {
-						return fmt.Errorf("invalid bearer bitmask: %w", err)
-					} else if !yield("bearer_bitmask", string(b)) {
+					if v := bearerBitmask; v != 0 {
+						if b, err := v.MarshalText(); err != nil {
+							return fmt.Errorf("invalid bearer bitmask: %w", err)
+						} else if !yield("bearer_bitmask", string(b)) {
+							return nil
+						}
+					}
+				case o.TargetSDK < 28:
+					// network_type_bitmask isn't supported, so it must be lossless
+					if bearerBitmaskBack != s.NetworkTypeBitmask {
+						return fmt.Errorf("network type bitmask %s cannot be represented as a bearer bitmask (target %d)", s.NetworkTypeBitmask, o.TargetSDK)
+					}
+					if v := bearerBitmask; v != 0 {
+						if b, err := v.MarshalText(); err != nil {
+							return fmt.Errorf("invalid bearer bitmask: %w", err)
+						} else if !yield("bearer_bitmask", string(b)) {
+							return nil
+						}
+					}
+				default:
+					// bearer_bitmask is deprecated, replaced with network_type_bitmask in sdk 28
+					if b, err := s.NetworkTypeBitmask.MarshalText(); err != nil {
+						return fmt.Errorf("invalid network type bitmask: %w", err)
+					} else if !yield("network_type_bitmask", string(b)) {
 						return nil
 					}
 				}
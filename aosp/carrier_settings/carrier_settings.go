@@ -1,5 +1,10 @@
 package carrier_settings
 
 // https://cs.android.com/android/platform/superproject/+/android14-qpr3-release:tools/carrier_settings/proto/carrier_settings.proto
+//
+// Newer firmware may have protocols, APN types, and ApnItem fields which aren't
+// in that version, but they aren't added here until they can be checked against
+// a published version of the proto. Until then, carriersettings.ConvertAPN
+// skips them with a warning, and fails if none of the APN types are left.
 
 //go:generate protoc --go_out=.  --go_opt=Mcarrier_settings.proto=github.com/pgaskin/apn-extract-utils/aosp/carrier_settings --go_opt=paths=source_relative carrier_settings.proto
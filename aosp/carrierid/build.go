@@ -0,0 +1,238 @@
+package carrierid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// Builder merges overlays into a base CarrierList.
+//
+// Overlay entries with the canonical id of an existing entry are merged into
+// it: the carrier name and parent are replaced if set, and the attributes are
+// appended. Other entries are added to the end of the list.
+type Builder struct {
+	base    *CarrierList
+	list    *CarrierList
+	added   map[*CarrierAttribute]bool // attributes from overlays
+	version int32                      // highest overlay version
+}
+
+// NewBuilder creates a new Builder starting from a copy of base.
+func NewBuilder(base *CarrierList) *Builder {
+	return &Builder{
+		base:  base,
+		list:  proto.Clone(base).(*CarrierList),
+		added: map[*CarrierAttribute]bool{},
+	}
+}
+
+// Add merges overlay into the list. An error is returned if an entry doesn't
+// have a canonical id, or if the overlay has the same canonical id more than
+// once.
+func (b *Builder) Add(overlay *CarrierList) error {
+	seen := map[int32]bool{}
+	for i, c := range overlay.GetCarrierId() {
+		if c.CanonicalId == nil {
+			return fmt.Errorf("overlay entry %d: missing canonical id", i)
+		}
+		if seen[c.GetCanonicalId()] {
+			return fmt.Errorf("overlay entry %d: duplicate canonical id %d", i, c.GetCanonicalId())
+		}
+		seen[c.GetCanonicalId()] = true
+	}
+	b.version = max(b.version, overlay.GetVersion())
+
+	for _, c := range overlay.GetCarrierId() {
+		c = proto.Clone(c).(*CarrierId)
+		for _, a := range c.CarrierAttribute {
+			b.added[a] = true
+		}
+		i := slices.IndexFunc(b.list.CarrierId, func(x *CarrierId) bool {
+			return x.GetCanonicalId() == c.GetCanonicalId()
+		})
+		if i == -1 {
+			b.list.CarrierId = append(b.list.CarrierId, c)
+			continue
+		}
+		x := b.list.CarrierId[i]
+		if c.CarrierName != nil {
+			x.CarrierName = c.CarrierName
+		}
+		if c.ParentCanonicalId != nil {
+			x.ParentCanonicalId = c.ParentCanonicalId
+		}
+		x.CarrierAttribute = append(x.CarrierAttribute, c.CarrierAttribute...)
+	}
+	return nil
+}
+
+// AddText parses a textproto CarrierList and merges it into the list.
+func (b *Builder) AddText(buf []byte) error {
+	var overlay CarrierList
+	if err := prototext.Unmarshal(buf, &overlay); err != nil {
+		return fmt.Errorf("parse overlay: %w", err)
+	}
+	return b.Add(&overlay)
+}
+
+// AddFile reads a textproto CarrierList and merges it into the list.
+func (b *Builder) AddFile(name string) error {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := b.AddText(buf); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// CheckBase checks the base list like Index.Check. These problems are ignored
+// by Check and Build since the overlays didn't introduce them.
+func (b *Builder) CheckBase() []error {
+	return NewIndex(b.base).Check()
+}
+
+// Check checks the list like Index.Check, ignoring the problems which were
+// already in the base list (see CheckBase), and checks that the attributes added
+// by overlays have a mccmnc and don't collide with the attributes of other
+// carriers (i.e., they don't match the same SIMs with the same score, which
+// CarrierResolver resolves by order). Collisions with the parent or children
+// of a carrier are allowed.
+func (b *Builder) Check() []error {
+	var errs []error
+	base := map[CheckError]int{}
+	for _, err := range b.CheckBase() {
+		base[*err.(*CheckError)]++
+	}
+	idx := NewIndex(b.list)
+	for _, err := range idx.Check() {
+		if k := *err.(*CheckError); base[k] != 0 {
+			base[k]--
+			continue
+		}
+		errs = append(errs, err)
+	}
+
+	related := func(x, y *CarrierId) bool {
+		return x.GetCanonicalId() == y.GetCanonicalId() ||
+			(x.ParentCanonicalId != nil && x.GetParentCanonicalId() == y.GetCanonicalId()) ||
+			(y.ParentCanonicalId != nil && y.GetParentCanonicalId() == x.GetCanonicalId())
+	}
+	for _, c := range b.list.CarrierId {
+		for _, a := range c.CarrierAttribute {
+			if !b.added[a] {
+				continue
+			}
+			if len(a.MccmncTuple) == 0 {
+				errs = append(errs, fmt.Errorf("carrier id %d: attribute does not have a mccmnc", c.GetCanonicalId()))
+			}
+			var others []*CarrierId // which could collide (i.e., have an overlapping mccmnc)
+			for _, v := range a.MccmncTuple {
+				for _, o := range idx.ByMCCMNC(v) {
+					if !slices.Contains(others, o) {
+						others = append(others, o)
+					}
+				}
+			}
+			for _, o := range others {
+				if related(c, o) {
+					continue
+				}
+				for _, oa := range o.CarrierAttribute {
+					if (b.added[oa] && o.GetCanonicalId() < c.GetCanonicalId()) || !attributesCollide(a, oa) {
+						continue // only report collisions between added attributes once
+					}
+					errs = append(errs, fmt.Errorf("carrier id %d: attribute collides with carrier id %d (%s)", c.GetCanonicalId(), o.GetCanonicalId(), o.GetCarrierName()))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// attributesCollide checks if a and b have the same fields set, and if the
+// values of each field overlap. The values are compared case-insensitively
+// except for the mccmnc.
+func attributesCollide(a, b *CarrierAttribute) bool {
+	for _, x := range []struct {
+		A, B []string
+		Fold bool
+	}{
+		{a.MccmncTuple, b.MccmncTuple, false},
+		{a.ImsiPrefixXpattern, b.ImsiPrefixXpattern, true},
+		{a.Spn, b.Spn, true},
+		{a.Plmn, b.Plmn, true},
+		{a.Gid1, b.Gid1, true},
+		{a.Gid2, b.Gid2, true},
+		{a.PreferredApn, b.PreferredApn, true},
+		{a.IccidPrefix, b.IccidPrefix, true},
+		{a.PrivilegeAccessRule, b.PrivilegeAccessRule, true},
+	} {
+		if (len(x.A) == 0) != (len(x.B) == 0) {
+			return false
+		}
+		if len(x.A) != 0 && !slices.ContainsFunc(x.A, func(v string) bool {
+			return slices.ContainsFunc(x.B, func(w string) bool {
+				return v == w || (x.Fold && strings.EqualFold(v, w))
+			})
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Build checks the list (see Check) and returns it. If it is different from
+// the base, the version is set to one more than the base version, or to the
+// highest overlay version if that is higher.
+func (b *Builder) Build() (*CarrierList, error) {
+	if errs := b.Check(); len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	list := proto.Clone(b.list).(*CarrierList)
+	list.Version = b.base.Version
+	if !proto.Equal(list, b.base) {
+		list.Version = proto.Int32(max(b.base.GetVersion()+1, b.version))
+	}
+	return list, nil
+}
+
+// Marshal builds the list and encodes it as binary and textproto.
+func (b *Builder) Marshal() (pb, textpb []byte, err error) {
+	list, err := b.Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	if pb, err = (proto.MarshalOptions{Deterministic: true}).Marshal(list); err != nil {
+		return nil, nil, err
+	}
+	if textpb, err = (prototext.MarshalOptions{Multiline: true, Indent: "  "}).Marshal(list); err != nil {
+		return nil, nil, err
+	}
+	return pb, textpb, nil
+}
+
+// WriteFiles builds the list and writes it as binary to pbName and as textproto
+// to textpbName (if not empty).
+func (b *Builder) WriteFiles(pbName, textpbName string) error {
+	pb, textpb, err := b.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pbName, pb, 0666); err != nil {
+		return err
+	}
+	if textpbName != "" {
+		if err := os.WriteFile(textpbName, textpb, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
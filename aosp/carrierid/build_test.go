@@ -0,0 +1,61 @@
+package carrierid
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBuilderBaseProblems(t *testing.T) {
+	base := &CarrierList{
+		CarrierId: []*CarrierId{
+			{CanonicalId: proto.Int32(1), CarrierName: proto.String("a")},
+			{CanonicalId: proto.Int32(1), CarrierName: proto.String("a duplicate")},
+			{CanonicalId: proto.Int32(2), ParentCanonicalId: proto.Int32(3)},
+		},
+		Version: proto.Int32(5),
+	}
+	for _, tc := range []struct {
+		Name    string
+		Overlay string
+		Errors  int
+	}{
+		{"Empty", ``, 0},
+		{"Unrelated", `carrier_id { canonical_id: 10 carrier_attribute { mccmnc_tuple: "001001" } }`, 0},
+		{"Merged", `carrier_id { canonical_id: 1 carrier_name: "b" }`, 0},
+		{"MissingParent", `carrier_id { canonical_id: 2 } carrier_id { canonical_id: 10 parent_canonical_id: 11 }`, 1},
+		{"ChangedParent", `carrier_id { canonical_id: 2 parent_canonical_id: 4 }`, 1},
+		{"MissingMCCMNC", `carrier_id { canonical_id: 10 carrier_attribute { spn: "x" } }`, 1},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			b := NewBuilder(base)
+			if err := b.AddText([]byte(tc.Overlay)); err != nil {
+				t.Fatalf("add overlay: %v", err)
+			}
+			if errs := b.CheckBase(); len(errs) != 2 {
+				t.Errorf("expected 2 base problems, got %q", errs)
+			} else if want := [...]CheckError{
+				{CarrierID: 1, Kind: CheckDuplicateID},
+				{CarrierID: 2, Kind: CheckParentMissing, Parent: 3},
+			}; *errs[0].(*CheckError) != want[0] || *errs[1].(*CheckError) != want[1] {
+				t.Errorf("expected base problems %v, got %q", want, errs)
+			}
+			if errs := b.Check(); len(errs) != tc.Errors {
+				t.Errorf("expected %d problems, got %q", tc.Errors, errs)
+			}
+			list, err := b.Build()
+			if tc.Errors != 0 {
+				if err == nil {
+					t.Errorf("expected build to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if tc.Overlay == "" && !proto.Equal(list, base) {
+				t.Errorf("empty overlay changed the list")
+			}
+		})
+	}
+}
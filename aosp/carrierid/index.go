@@ -0,0 +1,214 @@
+package carrierid
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Index is an indexed view of a CarrierList. It must be recreated if the list
+// is modified.
+type Index struct {
+	list     *CarrierList
+	byID     map[int32]*CarrierId    // first entry for each id
+	byName   map[string][]*CarrierId // [lowercase name]
+	byMCCMNC map[string][]*CarrierId
+	byGID1   map[string][]*CarrierId // [lowercase gid1]
+	bySPN    map[string][]*CarrierId // [lowercase spn]
+	children map[int32][]*CarrierId
+}
+
+// NewIndex indexes l. Within each lookup, carriers are in the order of the
+// list, and each carrier is only included once.
+func NewIndex(l *CarrierList) *Index {
+	x := &Index{
+		list:     l,
+		byID:     map[int32]*CarrierId{},
+		byName:   map[string][]*CarrierId{},
+		byMCCMNC: map[string][]*CarrierId{},
+		byGID1:   map[string][]*CarrierId{},
+		bySPN:    map[string][]*CarrierId{},
+		children: map[int32][]*CarrierId{},
+	}
+	add := func(m map[string][]*CarrierId, k string, c *CarrierId) {
+		if cs := m[k]; len(cs) == 0 || cs[len(cs)-1] != c {
+			m[k] = append(cs, c)
+		}
+	}
+	for _, c := range l.GetCarrierId() {
+		if c.CanonicalId != nil {
+			if _, ok := x.byID[c.GetCanonicalId()]; !ok {
+				x.byID[c.GetCanonicalId()] = c
+			}
+		}
+		if c.CarrierName != nil {
+			add(x.byName, strings.ToLower(c.GetCarrierName()), c)
+		}
+		if c.ParentCanonicalId != nil {
+			x.children[c.GetParentCanonicalId()] = append(x.children[c.GetParentCanonicalId()], c)
+		}
+		for _, a := range c.CarrierAttribute {
+			for _, v := range a.MccmncTuple {
+				add(x.byMCCMNC, v, c)
+			}
+			for _, v := range a.Gid1 {
+				add(x.byGID1, strings.ToLower(v), c)
+			}
+			for _, v := range a.Spn {
+				add(x.bySPN, strings.ToLower(v), c)
+			}
+		}
+	}
+	return x
+}
+
+// List returns the indexed list.
+func (x *Index) List() *CarrierList {
+	return x.list
+}
+
+// ByID returns the carrier with the specified canonical id, or nil. If there
+// are duplicates, the first one is returned.
+func (x *Index) ByID(id int32) *CarrierId {
+	return x.byID[id]
+}
+
+// ByName returns the carriers with the specified name (case-insensitive).
+func (x *Index) ByName(name string) []*CarrierId {
+	return x.byName[strings.ToLower(name)]
+}
+
+// ByMCCMNC returns the carriers with an attribute for the specified mccmnc.
+func (x *Index) ByMCCMNC(mccmnc string) []*CarrierId {
+	return x.byMCCMNC[mccmnc]
+}
+
+// ByGID1 returns the carriers with an attribute for a GID1 which is a prefix of
+// gid1 (case-insensitive), like CarrierResolver. The carriers with shorter
+// prefixes are returned first.
+func (x *Index) ByGID1(gid1 string) []*CarrierId {
+	var cs []*CarrierId
+	gid1 = strings.ToLower(gid1)
+	for n := 1; n <= len(gid1); n++ {
+		for _, c := range x.byGID1[gid1[:n]] {
+			if !slices.Contains(cs, c) {
+				cs = append(cs, c)
+			}
+		}
+	}
+	return cs
+}
+
+// BySPN returns the carriers with an attribute for the specified SPN
+// (case-insensitive).
+func (x *Index) BySPN(spn string) []*CarrierId {
+	return x.bySPN[strings.ToLower(spn)]
+}
+
+// Parent returns the parent of the carrier with the specified id, or nil.
+func (x *Index) Parent(id int32) *CarrierId {
+	if c := x.byID[id]; c != nil && c.ParentCanonicalId != nil {
+		return x.byID[c.GetParentCanonicalId()]
+	}
+	return nil
+}
+
+// Children returns the carriers with the specified parent id.
+func (x *Index) Children(id int32) []*CarrierId {
+	return x.children[id]
+}
+
+// Ancestors returns the parent, grandparent, and so on, of the carrier with
+// the specified id, stopping at a missing parent or a cycle.
+func (x *Index) Ancestors(id int32) []*CarrierId {
+	var cs []*CarrierId
+	seen := map[int32]bool{id: true}
+	for p := x.Parent(id); p != nil && !seen[p.GetCanonicalId()]; p = x.Parent(p.GetCanonicalId()) {
+		seen[p.GetCanonicalId()] = true
+		cs = append(cs, p)
+	}
+	return cs
+}
+
+// Descendants returns the children, grandchildren, and so on, of the carrier
+// with the specified id, breadth-first.
+func (x *Index) Descendants(id int32) []*CarrierId {
+	var cs []*CarrierId
+	seen := map[*CarrierId]bool{}
+	for q := []int32{id}; len(q) != 0; q = q[1:] {
+		for _, c := range x.children[q[0]] {
+			if !seen[c] && c.GetCanonicalId() != id {
+				seen[c] = true
+				cs = append(cs, c)
+				q = append(q, c.GetCanonicalId())
+			}
+		}
+	}
+	return cs
+}
+
+// CheckErrorKind is the kind of problem found by Index.Check.
+type CheckErrorKind int
+
+const (
+	CheckDuplicateID   CheckErrorKind = iota + 1 // the canonical id is used by more than one entry
+	CheckParentCycle                             // the parent chain loops back to Parent
+	CheckParentMissing                           // Parent does not exist
+)
+
+// CheckError is a problem found by Index.Check.
+type CheckError struct {
+	CarrierID int32
+	Kind      CheckErrorKind
+	Parent    int32 // for CheckParentCycle and CheckParentMissing
+}
+
+func (e *CheckError) Error() string {
+	switch e.Kind {
+	case CheckDuplicateID:
+		return fmt.Sprintf("carrier id %d: duplicate canonical id", e.CarrierID)
+	case CheckParentCycle:
+		return fmt.Sprintf("carrier id %d: parent %d is a cycle", e.CarrierID, e.Parent)
+	case CheckParentMissing:
+		return fmt.Sprintf("carrier id %d: parent %d does not exist", e.CarrierID, e.Parent)
+	default:
+		return fmt.Sprintf("carrier id %d: invalid check error kind %d", e.CarrierID, int(e.Kind))
+	}
+}
+
+// Check checks that canonical ids are unique and that parent ids refer to
+// other carriers without cycles. The errors are *CheckError.
+func (x *Index) Check() []error {
+	var (
+		errs []error
+		seen = map[int32]bool{}
+	)
+	for _, c := range x.list.GetCarrierId() {
+		if c.CanonicalId == nil {
+			continue // upstream entries should always have one, but it isn't required
+		}
+		if seen[c.GetCanonicalId()] {
+			errs = append(errs, &CheckError{CarrierID: c.GetCanonicalId(), Kind: CheckDuplicateID})
+		}
+		seen[c.GetCanonicalId()] = true
+	}
+	for _, c := range x.list.GetCarrierId() {
+		if c.ParentCanonicalId == nil || x.byID[c.GetCanonicalId()] != c {
+			continue
+		}
+		ancestors := map[int32]bool{c.GetCanonicalId(): true}
+		for p := c; p.ParentCanonicalId != nil; {
+			id := p.GetParentCanonicalId()
+			if ancestors[id] {
+				errs = append(errs, &CheckError{CarrierID: c.GetCanonicalId(), Kind: CheckParentCycle, Parent: id})
+				break
+			}
+			ancestors[id] = true
+			if p = x.byID[id]; p == nil {
+				errs = append(errs, &CheckError{CarrierID: c.GetCanonicalId(), Kind: CheckParentMissing, Parent: id})
+				break
+			}
+		}
+	}
+	return errs
+}
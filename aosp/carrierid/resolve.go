@@ -0,0 +1,265 @@
+package carrierid
+
+import (
+	"slices"
+	"strings"
+)
+
+// https://cs.android.com/android/platform/superproject/main/+/main:frameworks/opt/telephony/src/java/com/android/internal/telephony/CarrierResolver.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae
+
+// UNKNOWN_CARRIER_ID is the carrier id used when there is no match.
+const UNKNOWN_CARRIER_ID = -1
+
+// Match scores, from CarrierResolver.CarrierMatchingRule. Higher scores are
+// more specific.
+const (
+	SCORE_MCCMNC                = 1 << 8
+	SCORE_IMSI_PREFIX           = 1 << 7
+	SCORE_ICCID_PREFIX          = 1 << 6
+	SCORE_GID1                  = 1 << 5
+	SCORE_GID2                  = 1 << 4
+	SCORE_PLMN                  = 1 << 3
+	SCORE_PRIVILEGE_ACCESS_RULE = 1 << 2
+	SCORE_SPN                   = 1 << 1
+	SCORE_APN                   = 1 << 0
+	SCORE_INVALID               = -1
+)
+
+// SIM describes a subscription to resolve. Empty fields are treated as
+// unavailable.
+type SIM struct {
+	MCCMNC               string
+	IMSI                 string
+	ICCID                string
+	GID1                 string
+	GID2                 string
+	PLMN                 string   // from EF_PNN
+	SPN                  string   // from EF_SPN
+	PreferredAPN         string   // the apn name of the preferred apn
+	PrivilegeAccessRules []string // hex certificate hashes
+}
+
+// Match is the result of resolving a SIM.
+type Match struct {
+	CarrierID  int32 // canonical carrier id (the parent if it matched as well as the specific carrier)
+	SpecificID int32 // most specific carrier id
+	ParentID   int32 // parent of the specific carrier id, or UNKNOWN_CARRIER_ID
+	Name       string
+	Score      int
+
+	// Carrier is the entry for SpecificID.
+	Carrier *CarrierId
+
+	// Attribute contains the values which matched the SIM, one per field
+	// which was set in the matching attribute.
+	Attribute *CarrierAttribute
+}
+
+// Resolver matches SIMs against a CarrierList like CarrierResolver.
+type Resolver struct {
+	rules map[string][]resolverRule // [mccmnc]
+}
+
+type resolverRule struct {
+	carrier *CarrierId
+	attr    *CarrierAttribute
+}
+
+// NewResolver creates a new Resolver for l. The list must not be modified
+// while the resolver is in use.
+func NewResolver(l *CarrierList) *Resolver {
+	r := &Resolver{
+		rules: map[string][]resolverRule{},
+	}
+	for _, c := range l.GetCarrierId() {
+		for _, a := range c.GetCarrierAttribute() {
+			for _, mccmnc := range a.GetMccmncTuple() {
+				r.rules[mccmnc] = append(r.rules[mccmnc], resolverRule{c, a})
+			}
+		}
+	}
+	return r
+}
+
+// Resolve finds the best match for sim, returning false if there is none.
+// Like CarrierResolver, only rules for the SIM's MCCMNC are considered, ties
+// are broken by the order of the list, and if a carrier and its parent have the
+// same score, the child is the specific carrier id and the parent is the
+// canonical one.
+func (r *Resolver) Resolve(sim SIM) (Match, bool) {
+	var (
+		maxScore     = SCORE_INVALID
+		maxRule      resolverRule
+		maxRuleMatch *CarrierAttribute
+		maxParent    resolverRule
+	)
+	for _, rule := range r.rules[sim.MCCMNC] {
+		score, m := matchAttribute(sim, rule.attr)
+		if score > maxScore {
+			maxScore = score
+			maxRule, maxRuleMatch = rule, m
+			maxParent = rule
+		} else if maxScore > SCORE_INVALID && score == maxScore {
+			if parentID(rule.carrier) == maxRule.carrier.GetCanonicalId() {
+				maxRule, maxRuleMatch = rule, m
+			} else if parentID(maxRule.carrier) == rule.carrier.GetCanonicalId() {
+				maxParent = rule
+			}
+		}
+	}
+	if maxScore == SCORE_INVALID {
+		return Match{
+			CarrierID:  UNKNOWN_CARRIER_ID,
+			SpecificID: UNKNOWN_CARRIER_ID,
+			ParentID:   UNKNOWN_CARRIER_ID,
+			Score:      SCORE_INVALID,
+		}, false
+	}
+	return Match{
+		CarrierID:  maxParent.carrier.GetCanonicalId(),
+		SpecificID: maxRule.carrier.GetCanonicalId(),
+		ParentID:   parentID(maxRule.carrier),
+		Name:       maxRule.carrier.GetCarrierName(),
+		Score:      maxScore,
+		Carrier:    maxRule.carrier,
+		Attribute:  maxRuleMatch,
+	}, true
+}
+
+// Matches returns a match for each carrier with an attribute matching sim,
+// sorted by descending score, then by the order of the list. Unlike Resolve,
+// each match is for that carrier alone (i.e., CarrierID is the same as
+// SpecificID).
+func (r *Resolver) Matches(sim SIM) []Match {
+	var ms []Match
+	for _, rule := range r.rules[sim.MCCMNC] {
+		score, m := matchAttribute(sim, rule.attr)
+		if score == SCORE_INVALID {
+			continue
+		}
+		if i := slices.IndexFunc(ms, func(x Match) bool { return x.Carrier == rule.carrier }); i != -1 {
+			if score > ms[i].Score {
+				ms[i].Score, ms[i].Attribute = score, m
+			}
+			continue
+		}
+		ms = append(ms, Match{
+			CarrierID:  rule.carrier.GetCanonicalId(),
+			SpecificID: rule.carrier.GetCanonicalId(),
+			ParentID:   parentID(rule.carrier),
+			Name:       rule.carrier.GetCarrierName(),
+			Score:      score,
+			Carrier:    rule.carrier,
+			Attribute:  m,
+		})
+	}
+	slices.SortStableFunc(ms, func(a, b Match) int {
+		return b.Score - a.Score
+	})
+	return ms
+}
+
+func parentID(c *CarrierId) int32 {
+	if c.ParentCanonicalId == nil {
+		return UNKNOWN_CARRIER_ID
+	}
+	return c.GetParentCanonicalId()
+}
+
+// matchAttribute scores a against sim. Since the CarrierIdProvider database
+// contains one row for every combination of the values of an attribute, and
+// the score only depends on the fields which are set, this is the same as
+// matching each row and taking the first with the highest score.
+func matchAttribute(sim SIM, a *CarrierAttribute) (int, *CarrierAttribute) {
+	var (
+		score int
+		m     CarrierAttribute
+	)
+	match := func(field []string, fieldScore int, out *[]string, fn func(v string) bool) bool {
+		if len(field) == 0 {
+			return true
+		}
+		for _, v := range field {
+			if fn(v) {
+				score += fieldScore
+				*out = []string{v}
+				return true
+			}
+		}
+		return false
+	}
+	if !match(a.MccmncTuple, SCORE_MCCMNC, &m.MccmncTuple, func(v string) bool {
+		return sim.MCCMNC != "" && sim.MCCMNC == v
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.ImsiPrefixXpattern, SCORE_IMSI_PREFIX, &m.ImsiPrefixXpattern, func(v string) bool {
+		return imsiPrefixMatch(sim.IMSI, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.IccidPrefix, SCORE_ICCID_PREFIX, &m.IccidPrefix, func(v string) bool {
+		return sim.ICCID != "" && strings.HasPrefix(sim.ICCID, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.Gid1, SCORE_GID1, &m.Gid1, func(v string) bool {
+		return gidMatch(sim.GID1, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.Gid2, SCORE_GID2, &m.Gid2, func(v string) bool {
+		return gidMatch(sim.GID2, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.Plmn, SCORE_PLMN, &m.Plmn, func(v string) bool {
+		return sim.PLMN != "" && strings.EqualFold(sim.PLMN, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.Spn, SCORE_SPN, &m.Spn, func(v string) bool {
+		return sim.SPN != "" && strings.EqualFold(sim.SPN, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.PrivilegeAccessRule, SCORE_PRIVILEGE_ACCESS_RULE, &m.PrivilegeAccessRule, func(v string) bool {
+		for _, x := range sim.PrivilegeAccessRules {
+			if v != "" && strings.EqualFold(v, x) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return SCORE_INVALID, nil
+	}
+	if !match(a.PreferredApn, SCORE_APN, &m.PreferredApn, func(v string) bool {
+		return sim.PreferredAPN != "" && strings.EqualFold(sim.PreferredAPN, v)
+	}) {
+		return SCORE_INVALID, nil
+	}
+	return score, &m
+}
+
+// imsiPrefixMatch checks whether imsi starts with pattern, where 'x' or 'X'
+// matches any digit.
+func imsiPrefixMatch(imsi, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	if len(imsi) < len(pattern) {
+		return false
+	}
+	for i := range len(pattern) {
+		if c := pattern[i]; c != 'x' && c != 'X' && c != imsi[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// gidMatch does a case-insensitive prefix match since the gid from some SIMs
+// have garbage at the end.
+func gidMatch(sim, gid string) bool {
+	return sim != "" && strings.HasPrefix(strings.ToLower(sim), strings.ToLower(gid))
+}
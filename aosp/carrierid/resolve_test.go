@@ -0,0 +1,86 @@
+package carrierid
+
+import (
+	"slices"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestResolve(t *testing.T) {
+	var list CarrierList
+	if err := prototext.Unmarshal([]byte(`
+		carrier_id { canonical_id: 1 carrier_name: "mccmnc" carrier_attribute { mccmnc_tuple: "302220" mccmnc_tuple: "302221" } }
+		carrier_id { canonical_id: 2 carrier_name: "spn" carrier_attribute { mccmnc_tuple: "302220" spn: "Spn" } }
+		carrier_id { canonical_id: 3 carrier_name: "gid1" carrier_attribute { mccmnc_tuple: "302220" gid1: "ba" } }
+		carrier_id { canonical_id: 4 carrier_name: "gid1 spn" carrier_attribute { mccmnc_tuple: "302220" gid1: "ba" spn: "spn" } }
+		carrier_id { canonical_id: 5 carrier_name: "imsi" carrier_attribute { mccmnc_tuple: "302220" imsi_prefix_xpattern: "30222012x4" } }
+		carrier_id { canonical_id: 6 carrier_name: "iccid" carrier_attribute { mccmnc_tuple: "302220" iccid_prefix: "893022" } }
+		carrier_id { canonical_id: 7 carrier_name: "child" parent_canonical_id: 2 carrier_attribute { mccmnc_tuple: "302220" spn: "spn" } }
+		carrier_id { canonical_id: 8 carrier_name: "tie" carrier_attribute { mccmnc_tuple: "302220" gid1: "BA" } }
+		carrier_id { canonical_id: 9 carrier_name: "plmn apn" carrier_attribute { mccmnc_tuple: "302220" plmn: "x" preferred_apn: "Internet" } }
+		carrier_id { canonical_id: 10 carrier_name: "privilege" carrier_attribute { mccmnc_tuple: "302220" privilege_access_rule: "AABB" } }
+		carrier_id { canonical_id: 11 carrier_name: "no mccmnc" carrier_attribute { spn: "spn" } }
+	`), &list); err != nil {
+		t.Fatalf("parse list: %v", err)
+	}
+	r := NewResolver(&list)
+
+	for _, tc := range []struct {
+		Name     string
+		SIM      SIM
+		Carrier  int32 // or UNKNOWN_CARRIER_ID if no match
+		Specific int32
+		Parent   int32
+		Score    int
+	}{
+		{"NoMCCMNC", SIM{SPN: "spn"}, UNKNOWN_CARRIER_ID, UNKNOWN_CARRIER_ID, UNKNOWN_CARRIER_ID, SCORE_INVALID},
+		{"UnknownMCCMNC", SIM{MCCMNC: "001001"}, UNKNOWN_CARRIER_ID, UNKNOWN_CARRIER_ID, UNKNOWN_CARRIER_ID, SCORE_INVALID},
+		{"MCCMNC", SIM{MCCMNC: "302221"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"MCCMNCOther", SIM{MCCMNC: "302220", SPN: "other"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"SPNParent", SIM{MCCMNC: "302220", SPN: "SPN"}, 2, 7, 2, SCORE_MCCMNC | SCORE_SPN},
+		{"GID1Prefix", SIM{MCCMNC: "302220", GID1: "BAFF"}, 3, 3, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_GID1},
+		{"GID1SPN", SIM{MCCMNC: "302220", GID1: "ba01", SPN: "spn"}, 4, 4, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_GID1 | SCORE_SPN},
+		{"GID1NotPrefix", SIM{MCCMNC: "302220", GID1: "b"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"IMSIPattern", SIM{MCCMNC: "302220", IMSI: "3022201294000"}, 5, 5, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_IMSI_PREFIX},
+		{"IMSIMismatch", SIM{MCCMNC: "302220", IMSI: "3022201290000"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"IMSITooShort", SIM{MCCMNC: "302220", IMSI: "302220129"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"IMSIOverSPN", SIM{MCCMNC: "302220", IMSI: "3022201204", SPN: "spn"}, 5, 5, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_IMSI_PREFIX},
+		{"ICCIDOverGID1", SIM{MCCMNC: "302220", ICCID: "8930220000", GID1: "ba"}, 6, 6, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_ICCID_PREFIX},
+		{"AllFields", SIM{MCCMNC: "302220", PLMN: "X", PreferredAPN: "internet"}, 9, 9, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_PLMN | SCORE_APN},
+		{"MissingField", SIM{MCCMNC: "302220", PLMN: "x"}, 1, 1, UNKNOWN_CARRIER_ID, SCORE_MCCMNC},
+		{"PrivilegeAccessRule", SIM{MCCMNC: "302220", PrivilegeAccessRules: []string{"0000", "aabb"}}, 10, 10, UNKNOWN_CARRIER_ID, SCORE_MCCMNC | SCORE_PRIVILEGE_ACCESS_RULE},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			m, ok := r.Resolve(tc.SIM)
+			if ok != (tc.Carrier != UNKNOWN_CARRIER_ID) {
+				t.Errorf("expected ok=%t, got %t", tc.Carrier != UNKNOWN_CARRIER_ID, ok)
+			}
+			if m.CarrierID != tc.Carrier || m.SpecificID != tc.Specific || m.ParentID != tc.Parent || m.Score != tc.Score {
+				t.Errorf("expected carrier=%d specific=%d parent=%d score=%d, got carrier=%d specific=%d parent=%d score=%d", tc.Carrier, tc.Specific, tc.Parent, tc.Score, m.CarrierID, m.SpecificID, m.ParentID, m.Score)
+			}
+			if ok && (m.Carrier == nil || m.Carrier.GetCanonicalId() != m.SpecificID) {
+				t.Errorf("incorrect carrier %v", m.Carrier)
+			}
+		})
+	}
+
+	t.Run("Attribute", func(t *testing.T) {
+		m, _ := r.Resolve(SIM{MCCMNC: "302220", IMSI: "3022201294000"})
+		want := &CarrierAttribute{MccmncTuple: []string{"302220"}, ImsiPrefixXpattern: []string{"30222012x4"}}
+		if !proto.Equal(m.Attribute, want) {
+			t.Errorf("expected attribute %v, got %v", want, m.Attribute)
+		}
+	})
+
+	t.Run("Matches", func(t *testing.T) {
+		var ids []int32
+		for _, m := range r.Matches(SIM{MCCMNC: "302220", GID1: "ba01", SPN: "spn"}) {
+			ids = append(ids, m.SpecificID)
+		}
+		if want := []int32{4, 3, 8, 2, 7, 1}; !slices.Equal(ids, want) {
+			t.Errorf("expected matches %v, got %v", want, ids)
+		}
+	})
+}
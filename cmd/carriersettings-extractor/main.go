@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
 	"fmt"
-	"io/fs"
 	"log/slog"
 	"maps"
 	"os"
@@ -10,7 +13,6 @@ import (
 	"path/filepath"
 	"reflect"
 	"slices"
-	"strconv"
 	"strings"
 
 	"github.com/pgaskin/apn-extract-utils/aosp/apn"
@@ -19,7 +21,6 @@ import (
 	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
 	"github.com/pgaskin/apn-extract-utils/aosp/carrierid"
 	"github.com/pgaskin/apn-extract-utils/source/carriersettings"
-	"github.com/pgaskin/xmlwriter"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 )
@@ -31,7 +32,6 @@ import (
 // https://android.googlesource.com/platform/packages/apps/CarrierConfig/+/master/src/com/android/carrierconfig/DefaultCarrierConfigService.java
 // https://cs.android.com/android/platform/superproject/main/+/main:frameworks/opt/telephony/src/java/com/android/internal/telephony/CarrierResolver.java;drc=be5b10f9022f6e4aeab9c39f50c1e6ac27e19eae;l=1018
 // TODO: rewrite this
-// TODO: rewrite the carrier id matching logic to actually match properly (the different fields have different levels of precedence, and matching is more than just string matching)
 
 func main() {
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
@@ -39,14 +39,46 @@ func main() {
 	})))
 
 	var (
-		src                           = os.DirFS("/data/android/lineage")
-		pixelCarrierSettings, _       = fs.Sub(src, "vendor/google/caiman/proprietary/product/etc/CarrierSettings")
-		onlyCarrierIDMatch            = false
+		carrierSettingsDir            = "/data/android/lineage/vendor/google/caiman/proprietary/product/etc/CarrierSettings"
+		carrierIdFile                 = "/data/android/lineage/packages/providers/TelephonyProvider/assets/sdk34_carrier_id/carrier_list.pb"
+		onlyCarrierIDMatch            = false // use carrier_id instead of mcc/mnc/mvno where possible
 		expandAdditionalFromCarrierID = true
 		debugDumpText                 = true
 		filterNameSuffix              = "" //"_ca"
+		targetSDK                     = 0
+		configDir                     = ""                           // if set, write carrier config xml files to this directory
+		vendorConfigDir               = ""                           // if set, write decoded vendor configs to this directory
+		failOnLint                    = ""                           // if set, exit with an error status if there are lint findings with at least this severity
+		mergePolicy                   = carriersettings.MergeReplace // how to combine settings for a carrier in multiple files
+		mappingReport                 = ""                           // if set, write the carrierId mapping report to this path with .json and .csv extensions
+		carrierIdOverlays             = []string{}                   // textproto CarrierList files to merge into the carrierId list
+		carrierIdOutput               = ""                           // if set, write the carrierId list (with overlays) to this path with .pb and .textpb extensions
 	)
 
+	flag.StringVar(&carrierSettingsDir, "carrier-settings", carrierSettingsDir, "read the carrier settings from this directory")
+	flag.StringVar(&carrierIdFile, "carrier-id", carrierIdFile, "read the carrierId list from this file (binary, textproto, or json, detected from the extension or the contents)")
+	flag.TextVar(&mergePolicy, "merge", mergePolicy, "how to combine settings for a carrier in multiple files (replace, fields, or version)")
+	flag.Func("carrier-id-overlay", "merge this textproto CarrierList file into the carrierId list (can be repeated)", func(s string) error {
+		carrierIdOverlays = append(carrierIdOverlays, s)
+		return nil
+	})
+	flag.StringVar(&carrierIdOutput, "carrier-id-out", carrierIdOutput, "write the carrierId list (with overlays) to this path with .pb and .textpb extensions")
+	flag.BoolVar(&onlyCarrierIDMatch, "only-carrier-id", onlyCarrierIDMatch, "write carrier_id rows without mcc/mnc, falling back to mcc/mnc/mvno rows for carrier_list entries without a carrier id")
+	flag.StringVar(&configDir, "config-dir", configDir, "write carrier config xml files to this directory")
+	flag.StringVar(&mappingReport, "mapping-report", mappingReport, "write the carrierId mapping report to this path with .json and .csv extensions")
+	flag.IntVar(&targetSDK, "target-sdk", targetSDK, "write apns-conf.xml for devices with this api level (0 for the latest)")
+	flag.StringVar(&vendorConfigDir, "vendor-config-dir", vendorConfigDir, "write decoded vendor configs to this directory")
+	flag.StringVar(&failOnLint, "fail-on-lint", failOnLint, "exit with an error status if there are apn lint findings with at least this severity (info, warning, or error)")
+	flag.Parse()
+
+	var failOnLintSeverity apn.Severity
+	if failOnLint != "" {
+		if err := failOnLintSeverity.UnmarshalText([]byte(failOnLint)); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid value for -fail-on-lint: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
 	txt := prototext.MarshalOptions{
 		EmitUnknown:  true,
 		Indent:       "  ",
@@ -57,84 +89,123 @@ func main() {
 		os.MkdirAll("dbg", 0777)
 	}
 
-	carrierId, err := openProto[*carrierid.CarrierList](src, "packages/providers/TelephonyProvider/assets/sdk34_carrier_id/carrier_list.pb")
+	carrierId, err := openProto[*carrierid.CarrierList](carrierIdFile)
 	if err != nil {
 		panic(err)
 	}
 	slog.Info("loaded carrier identification", "total", len(carrierId.CarrierId))
 
+	if len(carrierIdOverlays) != 0 || carrierIdOutput != "" {
+		b := carrierid.NewBuilder(carrierId)
+		for _, name := range carrierIdOverlays {
+			if err := b.AddFile(name); err != nil {
+				panic(err)
+			}
+		}
+		for _, err := range b.Check() {
+			slog.Error("invalid carrier identification overlay", "error", err)
+		}
+		if carrierId, err = b.Build(); err != nil {
+			panic(err)
+		}
+		slog.Info("applied carrier identification overlays", "total", len(carrierId.CarrierId), "version", carrierId.GetVersion())
+
+		if carrierIdOutput != "" {
+			if err := b.WriteFiles(carrierIdOutput+".pb", carrierIdOutput+".textpb"); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	for _, err := range carrierid.NewIndex(carrierId).Check() {
+		slog.Warn("inconsistent carrier identification", "error", err)
+	}
+
 	if debugDumpText {
-		buf, _ := txt.Marshal(carrierId)
+		buf, _ := carriersettings.MarshalText(txt, carrierId)
 		os.WriteFile(filepath.Join("dbg", "carrierId.textpb"), buf, 0666)
 	}
 
-	carrierList, err := openProto[*carrier_list.CarrierList](pixelCarrierSettings, "carrier_list.pb")
+	db, err := carriersettings.LoadOptions{Merge: mergePolicy}.Load(os.DirFS(carrierSettingsDir))
 	if err != nil {
 		panic(err)
 	}
-	slog.Info("loaded carrier list", "total", len(carrierList.Entry))
+	for _, err := range db.Errors {
+		slog.Error("failed to load carrier settings", "error", err)
+	}
+	slog.Info("loaded carrier list", "total", len(db.CarrierList.Entry))
 
 	if debugDumpText {
-		buf, _ := txt.Marshal(carrierList)
-		os.WriteFile(filepath.Join("dbg", "carrier_list.textpb"), buf, 0666)
+		// the files as they were loaded, so they can be edited and loaded again
+		for _, name := range slices.Sorted(maps.Keys(db.Files)) {
+			fn := filepath.Join("dbg", strings.TrimSuffix(filepath.FromSlash(name), path.Ext(name))+".textpb")
+			os.MkdirAll(filepath.Dir(fn), 0777)
+			buf, _ := carriersettings.MarshalText(txt, db.Files[name])
+			os.WriteFile(fn, buf, 0666)
+		}
+		os.MkdirAll(filepath.Join("dbg", "merged"), 0777)
 	}
 
-	tier2Settings, err := openProto[*carrier_settings.MultiCarrierSettings](pixelCarrierSettings, "others.pb")
-	if err != nil {
-		panic(err)
+	var unknownFields carriersettings.UnknownFields
+	unknownFields.Scan(carrierId, "carrierId")
+	for _, f := range slices.Concat(unknownFields.Fields(), db.UnknownFields()) {
+		slog.Warn("found unknown protobuf field", "message", f.Message, "number", f.Number, "wire_type", f.WireType, "count", f.Count, "examples", f.Examples, "value", f.Value)
 	}
 
-	if debugDumpText {
-		buf, _ := txt.Marshal(tier2Settings)
-		os.WriteFile(filepath.Join("dbg", "others.textpb"), buf, 0666)
+	for _, o := range db.Overwrites {
+		slog.Warn("combining carrier settings", "canonical_name", o.CanonicalName, "old", o.Old, "new", o.New, "policy", o.Policy, "kept_old", o.Kept)
 	}
 
 	allSettings := map[string]*carrier_settings.CarrierSettings{} // [canonicalName]
-	for _, cs := range tier2Settings.Setting {
-		if filterNameSuffix != "" && !strings.HasSuffix(cs.GetCanonicalName(), filterNameSuffix) {
+	for canonicalName, cs := range db.Settings {
+		if filterNameSuffix != "" && !strings.HasSuffix(canonicalName, filterNameSuffix) {
 			continue
 		}
-		slog.Debug("loaded tier 2 carrier settings", "canonical_name", cs.GetCanonicalName())
-		allSettings[*cs.CanonicalName] = cs
+		if debugDumpText && isFileName(canonicalName) {
+			buf, _ := carriersettings.MarshalText(txt, cs)
+			os.WriteFile(filepath.Join("dbg", "merged", canonicalName+".textpb"), buf, 0666)
+		}
+		slog.Debug("loaded carrier settings", "canonical_name", canonicalName, "name", db.Source[canonicalName])
+		allSettings[canonicalName] = cs
 	}
-	slog.Info("loaded tier 2 carrier settings", "total", len(tier2Settings.Setting))
+	slog.Info("loaded carrier settings", "total", len(allSettings))
 
-	var tier1Settings int
-	if err := fs.WalkDir(pixelCarrierSettings, ".", func(name string, d fs.DirEntry, err error) error {
-		if path.Ext(name) != ".pb" || name == "carrier_list.pb" || name == "others.pb" {
-			return nil
-		}
-		carrierSettings, err := openProto[*carrier_settings.CarrierSettings](pixelCarrierSettings, name)
-		if err != nil {
-			return err
-		}
-		if filterNameSuffix != "" && !strings.HasSuffix(carrierSettings.GetCanonicalName(), filterNameSuffix) {
-			return nil
-		}
-		if debugDumpText {
-			buf, _ := txt.Marshal(carrierSettings)
-			os.WriteFile(filepath.Join("dbg", strings.TrimSuffix(filepath.FromSlash(name), ".pb")+".textpb"), buf, 0666)
-		}
-		tier1Settings++
-		slog.Debug("loaded settings", "name", name, "canonical_name", carrierSettings.GetCanonicalName())
-		if _, ok := allSettings[*carrierSettings.CanonicalName]; ok {
-			slog.Warn("replacing tier 2 settings", "name", name, "canonical_name", carrierSettings.GetCanonicalName())
+	// grouped by key since the same issues are usually in many carriers
+	type configIssueKey struct {
+		Key  string
+		Kind carriersettings.ConfigIssueKind
+	}
+	configIssues := map[configIssueKey][]string{} // [key] canonicalNames
+	configIssueMessage := map[configIssueKey]string{}
+	for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
+		for _, is := range carriersettings.ValidateConfig(allSettings[canonicalName].GetConfigs()) {
+			k := configIssueKey{is.Key, is.Kind}
+			if _, ok := configIssueMessage[k]; !ok {
+				configIssueMessage[k] = is.Message
+			}
+			if cns := configIssues[k]; len(cns) == 0 || cns[len(cns)-1] != canonicalName {
+				configIssues[k] = append(cns, canonicalName)
+			}
 		}
-		allSettings[*carrierSettings.CanonicalName] = carrierSettings
-		return nil
-	}); err != nil {
-		panic(err)
 	}
-	slog.Info("loaded tier 1 carrier settings", "total", tier1Settings)
-
-	carrierMap := map[string][]*carrier_list.CarrierMap{} // [canonicalName]
-	for _, c := range carrierList.Entry {
-		canonicalName := c.GetCanonicalName()
-		if filterNameSuffix != "" && !strings.HasSuffix(canonicalName, filterNameSuffix) {
-			continue
+	for _, k := range slices.SortedFunc(maps.Keys(configIssues), func(a, b configIssueKey) int {
+		return cmp.Or(cmp.Compare(a.Kind, b.Kind), cmp.Compare(a.Key, b.Key))
+	}) {
+		level := slog.LevelWarn
+		switch k.Kind {
+		case carriersettings.ConfigIssueUnknown:
+			level = slog.LevelInfo
+		case carriersettings.ConfigIssueVendor, carriersettings.ConfigIssueDefault:
+			level = slog.LevelDebug
 		}
-		carrierMap[canonicalName] = append(carrierMap[canonicalName], c)
+		cns := configIssues[k]
+		slog.Log(context.Background(), level, "found carrier config issue", "key", k.Key, "kind", k.Kind, "message", configIssueMessage[k], "count", len(cns), "examples", cns[:min(len(cns), 5)])
 	}
+
+	carrierMap := db.CarrierMap() // [canonicalName]
+	maps.DeleteFunc(carrierMap, func(canonicalName string, _ []*carrier_list.CarrierMap) bool {
+		return filterNameSuffix != "" && !strings.HasSuffix(canonicalName, filterNameSuffix)
+	})
 	for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
 		if len(carrierMap[canonicalName]) == 0 {
 			slog.Error("failed to find carrier_list entry for carrier, dropping", "canonical_name", canonicalName)
@@ -144,80 +215,41 @@ func main() {
 	}
 	slog.Info("mapped carrier_settings to carrier_list entries")
 
-	carrierMapID := map[string][]*carrierid.CarrierId{} // [canonicalName]
+	carrierMapID := map[string][]*carrierid.CarrierId{}            // [canonicalName]
+	carrierMapUnresolved := map[string][]*carrier_list.CarrierId{} // [canonicalName] without an exact carrierId match
+	carrierIdIndex := carrierid.NewIndex(carrierId)
+	carrierIdResolver := carrierid.NewResolver(carrierId)
+	carrierIdPos := map[*carrierid.CarrierId]int{} // [carrier] index in carrierId.CarrierId
+	for i, c := range carrierId.CarrierId {
+		carrierIdPos[c] = i
+	}
 	carrierIdMatchedExact := map[int]string{}
+	ruleMatch := map[*carrier_list.CarrierId]carriersettings.MappingRule{} // for the report
+	collisions := map[string][]string{}                                    // [canonicalName] for the report
+	addCollision := func(a, b string) {
+		if !slices.Contains(collisions[a], b) {
+			collisions[a] = append(collisions[a], b)
+		}
+		if !slices.Contains(collisions[b], a) {
+			collisions[b] = append(collisions[b], a)
+		}
+	}
 	for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
 		carrier := carrierMap[canonicalName]
 		for _, cs := range carrier {
 			for _, wantMatch := range cs.CarrierId {
-				i := slices.IndexFunc(carrierId.CarrierId, func(c *carrierid.CarrierId) bool {
-					return slices.ContainsFunc(c.CarrierAttribute, func(a *carrierid.CarrierAttribute) bool {
-						if !slices.Contains(a.MccmncTuple, *wantMatch.MccMnc) {
-							return false
-						}
-						if wantMatch.MvnoData == nil {
-							return len(a.ImsiPrefixXpattern) == 0 &&
-								len(a.Spn) == 0 &&
-								len(a.Plmn) == 0 &&
-								len(a.Gid1) == 0 &&
-								len(a.Gid2) == 0 &&
-								len(a.PreferredApn) == 0 &&
-								len(a.IccidPrefix) == 0 &&
-								len(a.PrivilegeAccessRule) == 0
-						}
-						switch wantData := wantMatch.MvnoData.(type) {
-						case *carrier_list.CarrierId_Spn:
-							return len(a.ImsiPrefixXpattern) == 0 &&
-								slices.ContainsFunc(a.Spn, func(e string) bool {
-									return strings.EqualFold(e, wantData.Spn)
-								}) &&
-								len(a.Plmn) == 0 &&
-								len(a.Gid1) == 0 &&
-								len(a.Gid2) == 0 &&
-								len(a.PreferredApn) == 0 &&
-								len(a.IccidPrefix) == 0 &&
-								len(a.PrivilegeAccessRule) == 0
-						case *carrier_list.CarrierId_Imsi:
-							return slices.ContainsFunc(a.ImsiPrefixXpattern, func(matchPattern string) bool {
-								if len(matchPattern) < len(wantData.Imsi) {
-									return false
-								}
-								for i, wantDigit := range []byte(wantData.Imsi) {
-									matchDigit := matchPattern[i]
-									switch {
-									case wantDigit == matchDigit:
-									case (wantDigit == 'x' || wantDigit == 'X') && (matchDigit == 'x' || matchDigit == 'X'):
-									case matchDigit == 'x' || matchDigit == 'X':
-									default:
-										return false
-									}
-								}
-								return true
-							}) &&
-								len(a.Spn) == 0 &&
-								len(a.Plmn) == 0 &&
-								len(a.Gid1) == 0 &&
-								len(a.Gid2) == 0 &&
-								len(a.PreferredApn) == 0 &&
-								len(a.IccidPrefix) == 0 &&
-								len(a.PrivilegeAccessRule) == 0
-						case *carrier_list.CarrierId_Gid1:
-							return len(a.ImsiPrefixXpattern) == 0 &&
-								len(a.Spn) == 0 &&
-								len(a.Plmn) == 0 &&
-								slices.ContainsFunc(a.Gid1, func(e string) bool {
-									return strings.EqualFold(e, wantData.Gid1)
-								}) &&
-								len(a.Gid2) == 0 &&
-								len(a.PreferredApn) == 0 &&
-								len(a.IccidPrefix) == 0 &&
-								len(a.PrivilegeAccessRule) == 0
-						default:
-							panic("unhandled mnvo data type")
-						}
-					})
-				})
-				if i != -1 {
+				// a sim matching the carrier_list entry must resolve to a carrier id using the same rule
+				resolved := carriersettings.ResolveCarrierMatch(carrierIdResolver, wantMatch)
+				var matched []int32 // every exact match, for the report
+				for _, c := range resolved {
+					matched = append(matched, c.GetCanonicalId())
+				}
+				if len(matched) > 1 {
+					slog.Debug("multiple carrierIds matched a carrier_list entry exactly, using the resolved one", "canonical_name", canonicalName, "carrier_ids", matched)
+				}
+				if len(resolved) != 0 {
+					i := carrierIdPos[resolved[0]]
+
 					// TODO: improve this, maybe filter by all instead of one
 					other, ok := carrierIdMatchedExact[i]
 					if !ok || other != canonicalName {
@@ -225,10 +257,17 @@ func main() {
 					}
 					if ok && other != canonicalName {
 						slog.Warn("multiple carriersettings carriers matched a single carrierId exactly", "canonical_name", canonicalName, "other_canonical_name", other, "carrier_id", *carrierId.CarrierId[i].CanonicalId)
+						addCollision(canonicalName, other)
+					}
+					ruleMatch[wantMatch] = carriersettings.MappingRule{
+						Kind:       carriersettings.MatchExact,
+						CarrierIDs: matched,
 					}
 					if !slices.Contains(carrierMapID[canonicalName], carrierId.CarrierId[i]) {
 						carrierMapID[canonicalName] = append(carrierMapID[canonicalName], carrierId.CarrierId[i])
 					}
+				} else {
+					carrierMapUnresolved[canonicalName] = append(carrierMapUnresolved[canonicalName], wantMatch)
 				}
 			}
 		}
@@ -250,15 +289,12 @@ func main() {
 		for _, cs := range carrier {
 			for _, wantMatch := range cs.CarrierId {
 				var possibleMatches []int
-				for i, c := range carrierId.CarrierId {
+				for _, c := range carrierIdIndex.ByMCCMNC(*wantMatch.MccMnc) {
+					i := carrierIdPos[c]
 					if _, ok := carrierIdMatchedExact[i]; ok {
 						continue
 					}
-					if slices.ContainsFunc(c.CarrierAttribute, func(a *carrierid.CarrierAttribute) bool {
-						return slices.Contains(a.MccmncTuple, *wantMatch.MccMnc)
-					}) {
-						possibleMatches = append(possibleMatches, i)
-					}
+					possibleMatches = append(possibleMatches, i)
 				}
 				if len(possibleMatches) == 1 {
 					// TODO: improve this
@@ -270,22 +306,128 @@ func main() {
 					}
 					if ok && other != canonicalName {
 						slog.Warn("multiple carriersettings carriers which idn't match a single carrierId exactly matched a non-exactly-matched carrierId by the just the PLMN", "canonical_name", canonicalName, "other_canonical_name", other, "carrier_id", *carrierId.CarrierId[i].CanonicalId)
+						addCollision(canonicalName, other)
+					}
+					ruleMatch[wantMatch] = carriersettings.MappingRule{
+						Kind:       carriersettings.MatchPLMN,
+						CarrierIDs: []int32{carrierId.CarrierId[i].GetCanonicalId()},
 					}
 					if !slices.Contains(carrierMapID[canonicalName], carrierId.CarrierId[i]) {
 						carrierMapID[canonicalName] = append(carrierMapID[canonicalName], carrierId.CarrierId[i])
 					}
+					carrierMapUnresolved[canonicalName] = slices.DeleteFunc(carrierMapUnresolved[canonicalName], func(c *carrier_list.CarrierId) bool {
+						return c == wantMatch
+					})
 				}
 			}
 		}
 	}
 	slog.Info("mapped carrier_list entries to carrierId (plus plmn-only matches of remaining carrierId entries)", "have", len(carrierMapID), "missing", len(carrierMap)-len(carrierMapID))
 
+	if mappingReport != "" {
+		var rows []carriersettings.MappingRow
+		for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
+			row := carriersettings.MappingRow{
+				CanonicalName: canonicalName,
+				CarrierIDs:    []int32{},
+				Rules:         []carriersettings.MappingRule{},
+				Collisions:    slices.Sorted(slices.Values(collisions[canonicalName])),
+				APNs:          len(allSettings[canonicalName].GetApns().GetApn()),
+			}
+			for _, c := range carrierMapID[canonicalName] {
+				row.CarrierIDs = append(row.CarrierIDs, c.GetCanonicalId())
+			}
+			for _, cs := range carrierMap[canonicalName] {
+				for _, c := range cs.CarrierId {
+					r := ruleMatch[c]
+					r.Match = carriersettings.FormatCarrierMatch(c)
+					if r.CarrierIDs == nil {
+						r.CarrierIDs = []int32{}
+					}
+					row.Kind = max(row.Kind, r.Kind)
+					row.Rules = append(row.Rules, r)
+				}
+			}
+			if row.Collisions == nil {
+				row.Collisions = []string{}
+			}
+			rows = append(rows, row)
+		}
+		var jsonBuf, csvBuf bytes.Buffer
+		if err := carriersettings.WriteMappingJSON(&jsonBuf, rows); err != nil {
+			panic(err)
+		}
+		if err := carriersettings.WriteMappingCSV(&csvBuf, rows); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(mappingReport+".json", jsonBuf.Bytes(), 0666); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(mappingReport+".csv", csvBuf.Bytes(), 0666); err != nil {
+			panic(err)
+		}
+		slog.Info("wrote carrierId mapping report", "path", mappingReport, "total", len(rows))
+	}
+
+	if configDir != "" {
+		if err := os.MkdirAll(configDir, 0777); err != nil {
+			panic(err)
+		}
+		for _, f := range db.ConfigFiles(carrierMapID) {
+			if !isFileName(f.Name) {
+				// the carrierconfig app wouldn't be able to open it either
+				slog.Error("invalid carrier config file name, skipping", "name", f.Name)
+				continue
+			}
+			var buf bytes.Buffer
+			if err := f.Encode(&buf); err != nil {
+				slog.Error("failed to convert carrier config, skipping", "name", f.Name, "error", err)
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(configDir, f.Name), buf.Bytes(), 0666); err != nil {
+				panic(err)
+			}
+		}
+		slog.Info("wrote carrier configs", "dir", configDir)
+	}
+
+	if vendorConfigDir != "" {
+		var n int
+		for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
+			for _, c := range allSettings[canonicalName].GetVendorConfigs().GetClient() {
+				if !isFileName(canonicalName) || !isFileName(c.GetName()) {
+					slog.Error("invalid vendor config file name, skipping", "canonical_name", canonicalName, "client", c.GetName())
+					continue
+				}
+				v, err := carriersettings.DecodeVendorConfig(c)
+				if err != nil {
+					slog.Warn("failed to decode vendor config, writing raw bytes", "canonical_name", canonicalName, "client", c.GetName(), "error", err)
+				}
+				buf, err := carriersettings.MarshalVendorConfigJSON(v)
+				if err != nil {
+					panic(err)
+				}
+				dir := filepath.Join(vendorConfigDir, canonicalName)
+				if err := os.MkdirAll(dir, 0777); err != nil {
+					panic(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, c.GetName()+".json"), buf, 0666); err != nil {
+					panic(err)
+				}
+				n++
+			}
+		}
+		slog.Info("wrote vendor configs", "dir", vendorConfigDir, "total", n)
+	}
+
 	type ConvertedAPN struct {
 		Comment       string
 		CanonicalName string
 		Setting       apn.Setting
 	}
 	var apns []ConvertedAPN
+	var lintFailed int
+	var fallbacks []string // [canonicalName] with carrier_list entries without a carrier id
 	for _, canonicalName := range slices.Sorted(maps.Keys(allSettings)) {
 		carrier := carrierMap[canonicalName]
 		carrierIDs := carrierMapID[canonicalName]
@@ -298,8 +440,71 @@ func main() {
 			continue
 		}
 
-		for _, src := range carrierSettings.Apns.Apn {
-			s, err := carriersettings.ConvertAPN(src)
+		var fallback []*carrier_list.CarrierId
+		if onlyCarrierIDMatch {
+			if fallback = carrierMapUnresolved[canonicalName]; len(fallback) != 0 {
+				slog.Warn("no carrier id for carrier_list entries, falling back to mccmnc/mvno match", "total", len(fallback))
+				fallbacks = append(fallbacks, canonicalName)
+			}
+		}
+
+		type ExpandedMatch struct {
+			CanonicalID int
+			Match       *carrier_list.CarrierId
+		}
+		var expanded []ExpandedMatch
+		if expandAdditionalFromCarrierID && !onlyCarrierIDMatch {
+			var covered []*carrier_list.CarrierId
+			for _, cs := range carrier {
+				covered = append(covered, cs.CarrierId...)
+			}
+			for _, cm := range carrierIDs {
+				if _, ok := carrierIdMatchedExact[carrierIdPos[cm]]; !ok {
+					// it was only guessed by the plmn, so its attributes may belong to another carrier
+					slog.Warn("not expanding apns from carrier id matched by the plmn only", "carrier_id", cm.GetCanonicalId())
+					continue
+				}
+				extra, errs := carriersettings.ExpandCarrierID(cm, covered)
+				for _, err := range errs {
+					slog.Warn("cannot express carrier id attribute as apn match, skipping", "carrier_id", cm.GetCanonicalId(), "error", err)
+				}
+				for _, c := range extra {
+					slog.Debug("expanding apns from carrier id attribute", "carrier_id", cm.GetCanonicalId(), "mccmnc", c.GetMccMnc(), "spn", c.GetSpn(), "imsi", c.GetImsi(), "gid1", c.GetGid1())
+					expanded = append(expanded, ExpandedMatch{int(cm.GetCanonicalId()), c})
+				}
+			}
+		}
+
+		// lints the apn with the carrier fields applied, then adds it
+		add := func(comment string, s apn.Setting) {
+			for _, f := range apn.Lint(s, nil) {
+				if f.Severity >= apn.SeverityWarning {
+					slog.Warn("lint finding for apn", "name", s.EntryName, "mccmnc", s.OperatorNumeric, "carrier_id", s.CarrierID, "rule", f.Rule, "severity", f.Severity, "message", f.Message)
+				} else {
+					slog.Info("lint finding for apn", "name", s.EntryName, "mccmnc", s.OperatorNumeric, "carrier_id", s.CarrierID, "rule", f.Rule, "severity", f.Severity, "message", f.Message)
+				}
+				if failOnLint != "" && f.Severity >= failOnLintSeverity {
+					lintFailed++
+				}
+			}
+			apns = append(apns, ConvertedAPN{
+				Comment:       comment,
+				CanonicalName: canonicalName,
+				Setting:       s,
+			})
+		}
+
+		for i, src := range carrierSettings.Apns.Apn {
+			comment := canonicalName
+			if i < len(db.APNSource[canonicalName]) {
+				file := db.APNSource[canonicalName][i]
+				comment += fmt.Sprintf(" (tier %d, %s)", carriersettings.FileTier(file), file)
+			}
+
+			s, warnings, err := carriersettings.ConvertAPN(src)
+			for _, w := range warnings {
+				slog.Warn("apn conversion warning", "apn", src.GetName(), "warning", w)
+			}
 			if err != nil {
 				slog.Error("failed to convert apn, skipping", "error", err)
 				continue
@@ -309,20 +514,19 @@ func main() {
 				continue
 			}
 
-			if err := s.Check(); err != nil {
-				slog.Warn("check failed for apn", "error", err)
-			}
-
 			if onlyCarrierIDMatch {
 				for _, c := range carrierIDs {
 					tmp := s
 					tmp.CarrierID = int(*c.CanonicalId)
 
-					apns = append(apns, ConvertedAPN{
-						Comment:       canonicalName,
-						CanonicalName: canonicalName,
-						Setting:       tmp,
-					})
+					add(comment, tmp)
+				}
+				for _, c := range fallback {
+					tmp, err := carriersettings.WithAPNCarrier(s, c)
+					if err != nil {
+						panic(err)
+					}
+					add(comment+" (mccmnc fallback)", tmp)
 				}
 			} else {
 				var canonicalIDs []int
@@ -342,60 +546,88 @@ func main() {
 							if canonicalID != -1 {
 								tmp.CarrierID = canonicalID
 							}
-							apns = append(apns, ConvertedAPN{
-								Comment:       canonicalName,
-								CanonicalName: canonicalName,
-								Setting:       tmp,
-							})
+							add(comment, tmp)
 						}
 					}
 				}
-				if expandAdditionalFromCarrierID {
-					for _, cm := range carrierIDs {
-						_ = cm
-						// TODO: expand from cm.CarrierAttribute? will need to warn if there is more than one match condition, since that can't be expressed
+				for _, e := range expanded {
+					tmp, err := carriersettings.WithAPNCarrier(s, e.Match)
+					if err != nil {
+						panic(err)
 					}
+					tmp.CarrierID = e.CanonicalID
+					add(comment+" (from carrier id)", tmp)
 				}
 			}
 		}
 	}
 	slog.Info("converted apns", "total", len(apns))
 
-	w := xmlwriter.New(os.Stdout)
-	w.Indent("  ")
-	w.Start(nil, "apns", xmlwriter.NS("").Bind(""))
-	w.Attr(nil, "version", strconv.Itoa(apnsconf.Version))
-	var last string
-	for _, c := range apns {
-		if last == "" || last != c.Comment {
-			last = c.Comment
-			w.BlankLine()
-			w.Comment(true, " "+c.Comment+" ")
-		}
-		w.Start(nil, "apn")
-		var err error
-		for k, v := range apnsconf.XMLAttrSeq(c.Setting, &err) {
-			w.Attr(nil, k, v)
-		}
-		if err != nil {
-			panic(err)
+	if onlyCarrierIDMatch {
+		for _, canonicalName := range fallbacks {
+			var matches []string
+			for _, c := range carrierMapUnresolved[canonicalName] {
+				matches = append(matches, carriersettings.FormatCarrierMatch(c))
+			}
+			slog.Warn("used mccmnc/mvno fallback for carrier_list entries without a carrier id", "canonical_name", canonicalName, "resolved_carrier_ids", len(carrierMapID[canonicalName]), "matches", matches)
+		}
+		slog.Info("used mccmnc/mvno fallbacks", "total", len(fallbacks))
+	}
+
+	deduped, conflicts := apn.Dedup(func() []apn.Setting {
+		ss := make([]apn.Setting, len(apns))
+		for i, c := range apns {
+			ss[i] = c.Setting
+		}
+		return ss
+	}())
+	for _, c := range conflicts {
+		var names []string
+		for _, j := range c.Deduped {
+			for _, i := range deduped[j].Sources {
+				if n := apns[i].CanonicalName; !slices.Contains(names, n) {
+					names = append(names, n)
+				}
+			}
+		}
+		slog.Warn("conflicting apns with the same key", "apn", c.Key.APNName, "mccmnc", c.Key.OperatorNumeric, "carrier_id", c.Key.CarrierID, "canonical_names", names, "variants", len(c.Deduped))
+	}
+	slog.Info("deduplicated apns", "total", len(deduped), "conflicts", len(conflicts))
+
+	enc := apnsconf.NewEncoder(os.Stdout)
+	enc.Options.TargetSDK = targetSDK
+	for _, d := range deduped {
+		c := apns[d.Sources[0]]
+		if err := enc.Encode(c.Comment, d.Setting); err != nil {
+			slog.Error("failed to encode apn, skipping", "canonical_name", c.CanonicalName, "error", err)
 		}
-		w.End(true)
 	}
-	w.End(false)
-	if err := w.Close(); err != nil {
+	if err := enc.Close(); err != nil {
 		panic(err)
 	}
+	if errs := enc.Errors(); len(errs) != 0 {
+		slog.Warn("skipped invalid apns", "total", len(errs))
+	}
+	if lintFailed != 0 {
+		slog.Error("apns have lint findings", "total", lintFailed, "min_severity", failOnLintSeverity)
+		os.Exit(1)
+	}
+}
+
+// isFileName checks if name (from the firmware) can be used as a single path
+// element.
+func isFileName(name string) bool {
+	return name != "" && name != "." && !strings.Contains(name, "..") && !strings.ContainsAny(name, "/\\\x00")
 }
 
-func openProto[T proto.Message](fsys fs.FS, fn string) (T, error) {
+func openProto[T proto.Message](fn string) (T, error) {
 	var z T
 	msg := reflect.New(reflect.TypeOf(z).Elem()).Interface().(T)
-	buf, err := fs.ReadFile(fsys, fn)
+	buf, err := os.ReadFile(fn)
 	if err != nil {
 		return z, fmt.Errorf("read %T from %q: %w", msg, fn, err)
 	}
-	if err := proto.Unmarshal(buf, msg); err != nil {
+	if err := carriersettings.UnmarshalProto(fn, buf, msg); err != nil {
 		return z, fmt.Errorf("read %T from %q: %w", msg, fn, err)
 	}
 	return msg, nil
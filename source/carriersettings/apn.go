@@ -1,19 +1,25 @@
 package carriersettings
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 
 	"github.com/pgaskin/apn-extract-utils/aosp/apn"
 	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
 	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 )
 
 // ConvertAPN converts src to an AOSP ApnSetting. It is as lenient as possible
-// while still rejecting values it cannot represent. It does not include the
+// while still rejecting values it cannot parse. Unrecognized enum values and
+// unknown fields are skipped and returned as warnings, but an error is returned
+// if none of the APN types are recognized. It does not include the
 // carrier match attributes (mcc/mnc/carrier_id/mvno_type/mvno_match_data).
-func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
-	s := apn.Empty()
+func ConvertAPN(src *carrier_settings.ApnItem) (s apn.Setting, warnings []error, err error) {
+	s = apn.Empty()
+	warnings = unknownFieldWarnings(src)
 	s.CarrierEnabled = true
 	s.InfrastructureBitmask = 0 // clear it so it isn't set in the xml
 
@@ -24,6 +30,10 @@ func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
 	for _, t := range src.GetType() {
 		switch t {
 		case carrier_settings.ApnItem_ALL:
+			if len(src.GetType()) > 1 {
+				// this can't be detected from the bitmask, since it's the same as listing every type in TYPE_ALL
+				warnings = append(warnings, fmt.Errorf("apn type ALL combined with explicit types %s", src.GetType()))
+			}
 			s.APNTypeBitmask |= apn.TYPE_ALL
 		case carrier_settings.ApnItem_DEFAULT:
 			s.APNTypeBitmask |= apn.TYPE_DEFAULT
@@ -52,13 +62,17 @@ func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
 		case carrier_settings.ApnItem_RCS:
 			s.APNTypeBitmask |= apn.TYPE_RCS
 		default:
-			return s, fmt.Errorf("unhandled apn type %#v", t)
+			warnings = append(warnings, fmt.Errorf("skipping unrecognized apn type %d", t))
 		}
 	}
+	if len(src.GetType()) != 0 && s.APNTypeBitmask == 0 {
+		// otherwise, it would be treated as a catch-all apn since an empty type means all types
+		return s, warnings, fmt.Errorf("no recognized apn types in %s", src.GetType())
+	}
 
 	if v := src.GetBearerBitmask(); v != "0" {
 		if err := s.BearerBitmask.UnmarshalText([]byte(v)); err != nil {
-			return s, fmt.Errorf("parse bearer bitmask: %w", err)
+			return s, warnings, fmt.Errorf("parse bearer bitmask: %w", err)
 		}
 		s.NetworkTypeBitmask = apn.ConvertBearerBitmaskToNetworkTypeBitmask(s.BearerBitmask)
 	}
@@ -68,7 +82,7 @@ func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
 	if v := src.GetPort(); v != "" {
 		v, err := strconv.ParseInt(v, 10, 0)
 		if err != nil {
-			return s, fmt.Errorf("parse proxy port: %w", err)
+			return s, warnings, fmt.Errorf("parse proxy port: %w", err)
 		}
 		s.ProxyPort = int(v)
 	}
@@ -81,39 +95,20 @@ func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
 	if v := src.GetMmscProxyPort(); v != "" {
 		v, err := strconv.ParseInt(v, 10, 0)
 		if err != nil {
-			return s, fmt.Errorf("parse mmsc proxy port: %w", err)
+			return s, warnings, fmt.Errorf("parse mmsc proxy port: %w", err)
 		}
 		s.MMSProxyPort = int(v)
 	}
 
-	switch v := src.GetProtocol(); v {
-	case carrier_settings.ApnItem_IP:
-		s.Protocol = apn.PROTOCOL_IP
-	case carrier_settings.ApnItem_IPV6:
-		s.Protocol = apn.PROTOCOL_IPV6
-	case carrier_settings.ApnItem_IPV4V6:
-		s.Protocol = apn.PROTOCOL_IPV4V6
-	case carrier_settings.ApnItem_PPP:
-		s.Protocol = apn.PROTOCOL_PPP
-	case 4: // TODO: update pb
-		s.Protocol = apn.PROTOCOL_NON_IP
-	default:
-		return s, fmt.Errorf("unhandled protocol %#v", v)
+	if v, ok := convertProtocol(src.GetProtocol()); ok {
+		s.Protocol = v
+	} else {
+		warnings = append(warnings, fmt.Errorf("skipping unrecognized protocol %d", src.GetProtocol()))
 	}
-
-	switch v := src.GetRoamingProtocol(); v {
-	case carrier_settings.ApnItem_IP:
-		s.RoamingProtocol = apn.PROTOCOL_IP
-	case carrier_settings.ApnItem_IPV6:
-		s.RoamingProtocol = apn.PROTOCOL_IPV6
-	case carrier_settings.ApnItem_IPV4V6:
-		s.RoamingProtocol = apn.PROTOCOL_IPV4V6
-	case carrier_settings.ApnItem_PPP:
-		s.RoamingProtocol = apn.PROTOCOL_PPP
-	case 4: // TODO: update pb
-		s.Protocol = apn.PROTOCOL_NON_IP
-	default:
-		return s, fmt.Errorf("unhandled roaming protocol %#v", v)
+	if v, ok := convertProtocol(src.GetRoamingProtocol()); ok {
+		s.RoamingProtocol = v
+	} else {
+		warnings = append(warnings, fmt.Errorf("skipping unrecognized roaming protocol %d", src.GetRoamingProtocol()))
 	}
 
 	if v := src.GetMtu(); v != 0 {
@@ -143,13 +138,45 @@ func ConvertAPN(src *carrier_settings.ApnItem) (apn.Setting, error) {
 	case carrier_settings.ApnItem_SKIP_464XLAT_ENABLE:
 		s.Skip464XLAT = apn.SKIP_464XLAT_ENABLE
 	default:
-		return s, fmt.Errorf("unhandled skip 464xlat value %#v", v)
+		warnings = append(warnings, fmt.Errorf("skipping unrecognized skip 464xlat value %d", v))
 	}
 
 	s.UserEditable = src.GetUserEditable()
 	s.UserVisible = src.GetUserVisible()
 
-	return s, nil
+	return s, warnings, nil
+}
+
+func convertProtocol(v carrier_settings.ApnItem_Protocol) (apn.Protocol, bool) {
+	switch v {
+	case carrier_settings.ApnItem_IP:
+		return apn.PROTOCOL_IP, true
+	case carrier_settings.ApnItem_IPV6:
+		return apn.PROTOCOL_IPV6, true
+	case carrier_settings.ApnItem_IPV4V6:
+		return apn.PROTOCOL_IPV4V6, true
+	case carrier_settings.ApnItem_PPP:
+		return apn.PROTOCOL_PPP, true
+	default:
+		return apn.PROTOCOL_UNKNOWN, false
+	}
+}
+
+// unknownFieldWarnings returns a warning for each unknown field in m. Note
+// that unrecognized enum values are not included, since protobuf-go treats all
+// enums as open.
+func unknownFieldWarnings(m proto.Message) []error {
+	var warnings []error
+	for b := m.ProtoReflect().GetUnknown(); len(b) != 0; {
+		num, _, n := protowire.ConsumeField(b)
+		if n < 0 {
+			warnings = append(warnings, fmt.Errorf("invalid unknown fields: %w", protowire.ParseError(n)))
+			break
+		}
+		b = b[n:]
+		warnings = append(warnings, fmt.Errorf("skipping unknown field %d", num))
+	}
+	return warnings
 }
 
 // WithAPNCarrier returns a copy of s with the carrier match attributes for an
@@ -181,3 +208,203 @@ func WithAPNCarrier(s apn.Setting, attr *carrier_list.CarrierId) (apn.Setting, e
 	}
 	return s, nil
 }
+
+// XCAPType controls how apn.TYPE_XCAP is represented in an ApnItem, since both
+// the XCAP and UT types are converted to it.
+type XCAPType int
+
+const (
+	XCAPTypeXCAP XCAPType = iota // XCAP
+	XCAPTypeUT                   // UT
+	XCAPTypeBoth                 // XCAP and UT
+)
+
+// ConvertSetting converts s to a CarrierSettings ApnItem. It is the reverse of
+// ConvertAPN, and returns an error if s has values which the ApnItem cannot
+// represent. Like ConvertAPN, it ignores the carrier match attributes
+// (mcc/mnc/carrier_id/mvno_type/mvno_match_data).
+func ConvertSetting(s apn.Setting, xcap XCAPType) (*carrier_settings.ApnItem, error) {
+	var errs []error
+	if !s.CarrierEnabled {
+		errs = append(errs, fmt.Errorf("cannot represent disabled carrier"))
+	}
+	if s.LingeringNetworkTypeBitmask != 0 {
+		errs = append(errs, fmt.Errorf("cannot represent lingering network type bitmask"))
+	}
+	if v := s.InfrastructureBitmask; v != 0 && v != apn.INFRASTRUCTURE_CELLULAR|apn.INFRASTRUCTURE_SATELLITE {
+		errs = append(errs, fmt.Errorf("cannot represent infrastructure bitmask %s", v))
+	}
+	if s.ESIMBootstrapProvisioning {
+		errs = append(errs, fmt.Errorf("cannot represent esim bootstrap provisioning"))
+	}
+	if s.AlwaysOn {
+		errs = append(errs, fmt.Errorf("cannot represent always on"))
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	dst := &carrier_settings.ApnItem{}
+	dst.Name = proto.String(s.EntryName)
+	dst.Value = proto.String(s.APNName)
+
+	t := s.APNTypeBitmask
+	if t&apn.TYPE_ALL == apn.TYPE_ALL {
+		dst.Type = append(dst.Type, carrier_settings.ApnItem_ALL)
+		t &^= apn.TYPE_ALL
+	}
+	for t := range t.Seq() {
+		switch t {
+		case apn.TYPE_DEFAULT:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_DEFAULT)
+		case apn.TYPE_MMS:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_MMS)
+		case apn.TYPE_SUPL:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_SUPL)
+		case apn.TYPE_DUN:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_DUN)
+		case apn.TYPE_HIPRI:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_HIPRI)
+		case apn.TYPE_FOTA:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_FOTA)
+		case apn.TYPE_IMS:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_IMS)
+		case apn.TYPE_CBS:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_CBS)
+		case apn.TYPE_IA:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_IA)
+		case apn.TYPE_EMERGENCY:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_EMERGENCY)
+		case apn.TYPE_XCAP:
+			switch xcap {
+			case XCAPTypeXCAP:
+				dst.Type = append(dst.Type, carrier_settings.ApnItem_XCAP)
+			case XCAPTypeUT:
+				dst.Type = append(dst.Type, carrier_settings.ApnItem_UT)
+			case XCAPTypeBoth:
+				dst.Type = append(dst.Type, carrier_settings.ApnItem_XCAP, carrier_settings.ApnItem_UT)
+			default:
+				return nil, fmt.Errorf("invalid xcap type %d", xcap)
+			}
+		case apn.TYPE_RCS:
+			dst.Type = append(dst.Type, carrier_settings.ApnItem_RCS)
+		default:
+			return nil, fmt.Errorf("unhandled apn type %s", t)
+		}
+	}
+
+	bb := s.BearerBitmask
+	if bb != 0 {
+		if x, y := apn.ConvertBearerBitmaskToNetworkTypeBitmask(bb), s.NetworkTypeBitmask; y != 0 && x != y {
+			return nil, fmt.Errorf("bearer bitmask %s does not match network type bitmask %s", bb, y)
+		}
+	} else if ntb := s.NetworkTypeBitmask; ntb != 0 {
+		bb = apn.ConvertNetworkTypeBitmaskToBearerBitmask(ntb)
+		if apn.ConvertBearerBitmaskToNetworkTypeBitmask(bb) != ntb {
+			return nil, fmt.Errorf("cannot represent network type bitmask %s as a bearer bitmask", ntb)
+		}
+	}
+	if bb != 0 {
+		v, err := bb.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("bearer bitmask: %w", err)
+		}
+		dst.BearerBitmask = proto.String(string(v))
+	}
+
+	if s.Server != "" {
+		dst.Server = proto.String(s.Server)
+	}
+	if s.ProxyAddress != "" {
+		dst.Proxy = proto.String(s.ProxyAddress)
+	}
+	if s.ProxyPort != -1 {
+		dst.Port = proto.String(strconv.Itoa(s.ProxyPort))
+	}
+	if s.User != "" {
+		dst.User = proto.String(s.User)
+	}
+	if s.Password != "" {
+		dst.Password = proto.String(s.Password)
+	}
+	if s.AuthType != apn.AUTH_TYPE_UNKNOWN {
+		dst.Authtype = proto.Int32(int32(s.AuthType))
+	}
+
+	if s.MMSC != "" {
+		dst.Mmsc = proto.String(s.MMSC)
+	}
+	if s.MMSProxyAddress != "" {
+		dst.MmscProxy = proto.String(s.MMSProxyAddress)
+	}
+	if s.MMSProxyPort != -1 {
+		dst.MmscProxyPort = proto.String(strconv.Itoa(s.MMSProxyPort))
+	}
+
+	for _, x := range []struct {
+		Name string
+		Src  apn.Protocol
+		Dst  **carrier_settings.ApnItem_Protocol
+	}{
+		{"protocol", s.Protocol, &dst.Protocol},
+		{"roaming protocol", s.RoamingProtocol, &dst.RoamingProtocol},
+	} {
+		switch x.Src {
+		case apn.PROTOCOL_UNKNOWN:
+		case apn.PROTOCOL_IP:
+			*x.Dst = carrier_settings.ApnItem_IP.Enum()
+		case apn.PROTOCOL_IPV6:
+			*x.Dst = carrier_settings.ApnItem_IPV6.Enum()
+		case apn.PROTOCOL_IPV4V6:
+			*x.Dst = carrier_settings.ApnItem_IPV4V6.Enum()
+		case apn.PROTOCOL_PPP:
+			*x.Dst = carrier_settings.ApnItem_PPP.Enum()
+		default:
+			return nil, fmt.Errorf("cannot represent %s %s", x.Name, x.Src)
+		}
+	}
+
+	if v4, v6 := s.MTUv4, s.MTUv6; v4 > 0 && v6 > 0 && v4 != v6 {
+		return nil, fmt.Errorf("cannot represent different ipv4 (%d) and ipv6 (%d) mtus", v4, v6)
+	} else if v := max(v4, v6); v > 0 {
+		dst.Mtu = proto.Int32(int32(v))
+	}
+
+	if s.ProfileID != 0 {
+		dst.ProfileId = proto.Int32(int32(s.ProfileID))
+	}
+	if s.MaxConns != 0 {
+		dst.MaxConns = proto.Int32(int32(s.MaxConns))
+	}
+	if s.WaitTime != 0 {
+		dst.WaitTime = proto.Int32(int32(s.WaitTime))
+	}
+	if s.MaxConnsTime != 0 {
+		dst.MaxConnsTime = proto.Int32(int32(s.MaxConnsTime))
+	}
+	if s.Persistent {
+		dst.ModemCognitive = proto.Bool(true)
+	}
+	if s.APNSetID != 0 {
+		dst.ApnSetId = proto.Int32(int32(s.APNSetID))
+	}
+
+	switch v := s.Skip464XLAT; v {
+	case apn.SKIP_464XLAT_DEFAULT:
+	case apn.SKIP_464XLAT_DISABLE:
+		dst.Skip_464Xlat = carrier_settings.ApnItem_SKIP_464XLAT_DISABLE.Enum()
+	case apn.SKIP_464XLAT_ENABLE:
+		dst.Skip_464Xlat = carrier_settings.ApnItem_SKIP_464XLAT_ENABLE.Enum()
+	default:
+		return nil, fmt.Errorf("unhandled skip 464xlat value %s", v)
+	}
+
+	if !s.UserEditable {
+		dst.UserEditable = proto.Bool(false)
+	}
+	if !s.UserVisible {
+		dst.UserVisible = proto.Bool(false)
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,68 @@
+package carriersettings
+
+import (
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/apn"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConvertAPNUnrecognized(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Apply    func(x *carrier_settings.ApnItem)
+		Warnings int
+		Error    bool
+	}{
+		{"Valid", func(x *carrier_settings.ApnItem) {}, 0, false},
+		{"Type", func(x *carrier_settings.ApnItem) {
+			x.Type = append(x.Type, 14)
+		}, 1, false},
+		{"OnlyUnrecognizedTypes", func(x *carrier_settings.ApnItem) {
+			x.Type = []carrier_settings.ApnItem_ApnType{14, 15}
+		}, 2, true},
+		{"Protocol", func(x *carrier_settings.ApnItem) {
+			x.Protocol = carrier_settings.ApnItem_Protocol(4).Enum()
+		}, 1, false},
+		{"RoamingProtocol", func(x *carrier_settings.ApnItem) {
+			x.RoamingProtocol = carrier_settings.ApnItem_Protocol(4).Enum()
+		}, 1, false},
+		{"Skip464XLAT", func(x *carrier_settings.ApnItem) {
+			x.Skip_464Xlat = carrier_settings.ApnItem_Xlat(3).Enum()
+		}, 1, false},
+		{"UnknownField", func(x *carrier_settings.ApnItem) {
+			x.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 27, protowire.VarintType), 1))
+		}, 1, false},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			x := &carrier_settings.ApnItem{
+				Name:     proto.String("Test"),
+				Value:    proto.String("test"),
+				Type:     []carrier_settings.ApnItem_ApnType{carrier_settings.ApnItem_DEFAULT},
+				Protocol: carrier_settings.ApnItem_IPV4V6.Enum(),
+			}
+			tc.Apply(x)
+			s, warnings, err := ConvertAPN(x)
+			if len(warnings) != tc.Warnings {
+				t.Errorf("expected %d warnings, got %q", tc.Warnings, warnings)
+			}
+			if tc.Error {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.APNTypeBitmask != apn.TYPE_DEFAULT {
+				t.Errorf("expected type %s, got %s", apn.TYPE_DEFAULT, s.APNTypeBitmask)
+			}
+			if s.Protocol != apn.PROTOCOL_IPV4V6 && s.Protocol != apn.PROTOCOL_UNKNOWN { // skipped, so left unset
+				t.Errorf("unrecognized protocol was converted to %s", s.Protocol)
+			}
+		})
+	}
+}
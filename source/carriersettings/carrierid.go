@@ -1,3 +1,175 @@
 package carriersettings
 
-// TODO: carrierid matching
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrierid"
+	"google.golang.org/protobuf/proto"
+)
+
+// ConvertCarrierAttribute converts a carrierId attribute to the equivalent
+// carrier_list match rules, one for each combination of mccmnc and value. An
+// error is returned if it can't be expressed as a single mvno_type and
+// mvno_match_data (i.e., if it has more than one kind of condition other than
+// the mccmnc, or a kind other than spn, imsi, or gid1).
+func ConvertCarrierAttribute(a *carrierid.CarrierAttribute) ([]*carrier_list.CarrierId, error) {
+	if len(a.MccmncTuple) == 0 {
+		return nil, fmt.Errorf("no mccmnc")
+	}
+	var unsupported []string
+	for _, x := range []struct {
+		Name string
+		Len  int
+	}{
+		{"plmn", len(a.Plmn)},
+		{"gid2", len(a.Gid2)},
+		{"preferred_apn", len(a.PreferredApn)},
+		{"iccid_prefix", len(a.IccidPrefix)},
+		{"privilege_access_rule", len(a.PrivilegeAccessRule)},
+	} {
+		if x.Len != 0 {
+			unsupported = append(unsupported, x.Name)
+		}
+	}
+	if len(unsupported) != 0 {
+		return nil, fmt.Errorf("cannot express %s", strings.Join(unsupported, ", "))
+	}
+
+	var (
+		kinds int
+		mvno  []func(*carrier_list.CarrierId)
+	)
+	if len(a.Spn) != 0 {
+		kinds++
+		for _, v := range a.Spn {
+			mvno = append(mvno, func(c *carrier_list.CarrierId) {
+				c.MvnoData = &carrier_list.CarrierId_Spn{Spn: v}
+			})
+		}
+	}
+	if len(a.ImsiPrefixXpattern) != 0 {
+		kinds++
+		for _, v := range a.ImsiPrefixXpattern {
+			mvno = append(mvno, func(c *carrier_list.CarrierId) {
+				c.MvnoData = &carrier_list.CarrierId_Imsi{Imsi: v}
+			})
+		}
+	}
+	if len(a.Gid1) != 0 {
+		kinds++
+		for _, v := range a.Gid1 {
+			mvno = append(mvno, func(c *carrier_list.CarrierId) {
+				c.MvnoData = &carrier_list.CarrierId_Gid1{Gid1: v}
+			})
+		}
+	}
+	if kinds > 1 {
+		return nil, fmt.Errorf("cannot express more than one of spn, imsi, and gid1")
+	}
+	if len(mvno) == 0 {
+		mvno = append(mvno, func(*carrier_list.CarrierId) {})
+	}
+
+	var cs []*carrier_list.CarrierId
+	for _, mccmnc := range a.MccmncTuple {
+		for _, fn := range mvno {
+			c := &carrier_list.CarrierId{MccMnc: proto.String(mccmnc)}
+			fn(c)
+			cs = append(cs, c)
+		}
+	}
+	return cs, nil
+}
+
+// CarrierMatchSIM returns a SIM with the mccmnc and mvno data of c. Since the
+// imsi is a pattern, each 'x' in it is replaced with '0'.
+func CarrierMatchSIM(c *carrier_list.CarrierId) carrierid.SIM {
+	sim := carrierid.SIM{MCCMNC: c.GetMccMnc()}
+	switch v := c.MvnoData.(type) {
+	case *carrier_list.CarrierId_Spn:
+		sim.SPN = v.Spn
+	case *carrier_list.CarrierId_Imsi:
+		sim.IMSI = strings.NewReplacer("x", "0", "X", "0").Replace(v.Imsi)
+	case *carrier_list.CarrierId_Gid1:
+		sim.GID1 = v.Gid1
+	}
+	return sim
+}
+
+// ResolveCarrierMatch resolves a SIM matching c (see CarrierMatchSIM) like
+// CarrierResolver. If the carrier it resolves to was matched by an attribute
+// with exactly the mccmnc and mvno data of c, it is returned first, followed by
+// any other carriers with such an attribute (which CarrierResolver doesn't
+// choose since they're later in the list). Otherwise, a device with that SIM
+// would be identified by a different rule, so nil is returned.
+func ResolveCarrierMatch(r *carrierid.Resolver, c *carrier_list.CarrierId) []*carrierid.CarrierId {
+	sim := CarrierMatchSIM(c)
+	exact := func(a *carrierid.CarrierAttribute) bool {
+		cs, err := ConvertCarrierAttribute(a)
+		return err == nil && len(cs) == 1 && SameCarrierMatch(cs[0], c)
+	}
+	m, ok := r.Resolve(sim)
+	if !ok || !exact(m.Attribute) {
+		return nil
+	}
+	cs := []*carrierid.CarrierId{m.Carrier}
+	for _, x := range r.Matches(sim) {
+		if x.Carrier != m.Carrier && exact(x.Attribute) {
+			cs = append(cs, x.Carrier)
+		}
+	}
+	return cs
+}
+
+// ExpandCarrierID returns the carrier_list match rules for the attributes of c
+// which aren't already in covered. Attributes which can't be expressed as
+// carrier_list match rules are returned as errors.
+func ExpandCarrierID(c *carrierid.CarrierId, covered []*carrier_list.CarrierId) ([]*carrier_list.CarrierId, []error) {
+	var (
+		extra []*carrier_list.CarrierId
+		errs  []error
+	)
+	for i, a := range c.CarrierAttribute {
+		cs, err := ConvertCarrierAttribute(a)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("carrier id %d attribute %d: %w", c.GetCanonicalId(), i, err))
+			continue
+		}
+		for _, x := range cs {
+			if !slices.ContainsFunc(covered, func(y *carrier_list.CarrierId) bool {
+				return SameCarrierMatch(x, y)
+			}) && !slices.ContainsFunc(extra, func(y *carrier_list.CarrierId) bool {
+				return SameCarrierMatch(x, y)
+			}) {
+				extra = append(extra, x)
+			}
+		}
+	}
+	return extra, errs
+}
+
+// SameCarrierMatch checks if a and b match the same carriers. SPN and GID1 are
+// compared case-insensitively.
+func SameCarrierMatch(a, b *carrier_list.CarrierId) bool {
+	if a.GetMccMnc() != b.GetMccMnc() {
+		return false
+	}
+	switch x := a.MvnoData.(type) {
+	case nil:
+		return b.MvnoData == nil
+	case *carrier_list.CarrierId_Spn:
+		y, ok := b.MvnoData.(*carrier_list.CarrierId_Spn)
+		return ok && strings.EqualFold(x.Spn, y.Spn)
+	case *carrier_list.CarrierId_Imsi:
+		y, ok := b.MvnoData.(*carrier_list.CarrierId_Imsi)
+		return ok && strings.EqualFold(x.Imsi, y.Imsi)
+	case *carrier_list.CarrierId_Gid1:
+		y, ok := b.MvnoData.(*carrier_list.CarrierId_Gid1)
+		return ok && strings.EqualFold(x.Gid1, y.Gid1)
+	default:
+		return false
+	}
+}
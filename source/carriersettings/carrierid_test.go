@@ -0,0 +1,172 @@
+package carriersettings
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrierid"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConvertCarrierAttribute(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Attr *carrierid.CarrierAttribute
+		Want []string // FormatCarrierMatch, nil if an error is expected
+	}{
+		{"MCCMNC", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220", "302221"},
+		}, []string{"302220", "302221"}},
+		{"SPN", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220", "302221"},
+			Spn:         []string{"a", "b"},
+		}, []string{"302220 spn=a", "302220 spn=b", "302221 spn=a", "302221 spn=b"}},
+		{"IMSI", &carrierid.CarrierAttribute{
+			MccmncTuple:        []string{"302220"},
+			ImsiPrefixXpattern: []string{"30222012x"},
+		}, []string{"302220 imsi=30222012x"}},
+		{"GID1", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220"},
+			Gid1:        []string{"ba01"},
+		}, []string{"302220 gid1=ba01"}},
+		{"NoMCCMNC", &carrierid.CarrierAttribute{
+			Spn: []string{"a"},
+		}, nil},
+		{"MultipleKinds", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220"},
+			Spn:         []string{"a"},
+			Gid1:        []string{"ba01"},
+		}, nil},
+		{"Unsupported", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220"},
+			IccidPrefix: []string{"8930"},
+		}, nil},
+		{"PLMN", &carrierid.CarrierAttribute{
+			MccmncTuple: []string{"302220"},
+			Plmn:        []string{"302220"},
+		}, nil},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			cs, err := ConvertCarrierAttribute(tc.Attr)
+			if tc.Want == nil {
+				if err == nil {
+					t.Errorf("expected error, got %q", formatCarrierMatches(cs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := formatCarrierMatches(cs); !slices.Equal(got, tc.Want) {
+				t.Errorf("expected %q, got %q", tc.Want, got)
+			}
+		})
+	}
+}
+
+func TestExpandCarrierID(t *testing.T) {
+	c := &carrierid.CarrierId{
+		CanonicalId: proto.Int32(1),
+		CarrierAttribute: []*carrierid.CarrierAttribute{
+			{MccmncTuple: []string{"302220"}, Spn: []string{"A", "b"}},
+			{MccmncTuple: []string{"302220"}, Spn: []string{"B"}}, // duplicate of the previous one
+			{MccmncTuple: []string{"302220"}, IccidPrefix: []string{"8930"}},
+			{MccmncTuple: []string{"302221"}},
+		},
+	}
+	covered := []*carrier_list.CarrierId{
+		{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Spn{Spn: "a"}},
+	}
+	extra, errs := ExpandCarrierID(c, covered)
+	if want, got := []string{"302220 spn=b", "302221"}, formatCarrierMatches(extra); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected 1 error for the iccid attribute, got %q", errs)
+	}
+}
+
+func TestSameCarrierMatch(t *testing.T) {
+	var (
+		plain = &carrier_list.CarrierId{MccMnc: proto.String("302220")}
+		other = &carrier_list.CarrierId{MccMnc: proto.String("302221")}
+		spn   = func(v string) *carrier_list.CarrierId {
+			return &carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Spn{Spn: v}}
+		}
+		imsi = func(v string) *carrier_list.CarrierId {
+			return &carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Imsi{Imsi: v}}
+		}
+		gid1 = func(v string) *carrier_list.CarrierId {
+			return &carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Gid1{Gid1: v}}
+		}
+	)
+	for _, tc := range []struct {
+		A, B *carrier_list.CarrierId
+		Want bool
+	}{
+		{plain, plain, true},
+		{plain, other, false},
+		{plain, spn("a"), false},
+		{spn("a"), plain, false},
+		{spn("a"), spn("A"), true},
+		{spn("a"), spn("b"), false},
+		{spn("a"), gid1("a"), false},
+		{imsi("30222012x"), imsi("30222012X"), true},
+		{imsi("30222012x"), imsi("302220123"), false},
+		{gid1("BA01"), gid1("ba01"), true},
+		{gid1("ba01"), imsi("ba01"), false},
+	} {
+		if got := SameCarrierMatch(tc.A, tc.B); got != tc.Want {
+			t.Errorf("%s == %s: expected %t, got %t", FormatCarrierMatch(tc.A), FormatCarrierMatch(tc.B), tc.Want, got)
+		}
+	}
+}
+
+func TestResolveCarrierMatch(t *testing.T) {
+	var list carrierid.CarrierList
+	if err := prototext.Unmarshal([]byte(`
+		carrier_id { canonical_id: 1 carrier_attribute { mccmnc_tuple: "302220" } }
+		carrier_id { canonical_id: 2 carrier_attribute { mccmnc_tuple: "302220" spn: "A" } }
+		carrier_id { canonical_id: 3 carrier_attribute { mccmnc_tuple: "302220" spn: "a" } }
+		carrier_id { canonical_id: 4 carrier_attribute { mccmnc_tuple: "302220" gid1: "ba" } }
+		carrier_id { canonical_id: 5 carrier_attribute { mccmnc_tuple: "302220" gid1: "ba01" } }
+		carrier_id { canonical_id: 6 carrier_attribute { mccmnc_tuple: "302220" imsi_prefix_xpattern: "30222012x" } }
+		carrier_id { canonical_id: 7 carrier_attribute { mccmnc_tuple: "302220" spn: "b" gid1: "ff" } }
+	`), &list); err != nil {
+		t.Fatalf("parse list: %v", err)
+	}
+	r := carrierid.NewResolver(&list)
+
+	for _, tc := range []struct {
+		Match *carrier_list.CarrierId
+		Want  []int32
+	}{
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220")}, []int32{1}},
+		{&carrier_list.CarrierId{MccMnc: proto.String("302221")}, nil},
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Spn{Spn: "a"}}, []int32{2, 3}},
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Spn{Spn: "c"}}, nil}, // resolves to 1 by the mccmnc
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Spn{Spn: "b"}}, nil}, // 7 also needs the gid1
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Gid1{Gid1: "BA"}}, []int32{4}},
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Gid1{Gid1: "ba01"}}, nil}, // resolves to 4 by the prefix
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Imsi{Imsi: "30222012X"}}, []int32{6}},
+		{&carrier_list.CarrierId{MccMnc: proto.String("302220"), MvnoData: &carrier_list.CarrierId_Imsi{Imsi: "302220123"}}, nil},
+	} {
+		var got []int32
+		for _, c := range ResolveCarrierMatch(r, tc.Match) {
+			got = append(got, c.GetCanonicalId())
+		}
+		if !slices.Equal(got, tc.Want) {
+			t.Errorf("%s: expected %v, got %v", FormatCarrierMatch(tc.Match), tc.Want, got)
+		}
+	}
+}
+
+func formatCarrierMatches(cs []*carrier_list.CarrierId) []string {
+	var ss []string
+	for _, c := range cs {
+		ss = append(ss, FormatCarrierMatch(c))
+	}
+	return ss
+}
@@ -0,0 +1,258 @@
+package carriersettings
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrierid"
+	"github.com/pgaskin/xmlwriter"
+)
+
+// https://cs.android.com/android/platform/superproject/main/+/main:tools/carrier_settings/java/CarrierConfigConverterV2.java
+// https://android.googlesource.com/platform/packages/apps/CarrierConfig/+/master/src/com/android/carrierconfig/DefaultCarrierConfigService.java
+// https://cs.android.com/android/platform/superproject/main/+/main:frameworks/base/core/java/android/os/PersistableBundle.java (restoreFromXml)
+
+var configFileNameSpace = regexp.MustCompile(`\s`)
+
+// CarrierIDConfigFileName returns the name of the config file for a carrier id,
+// which is used by the CarrierConfig app if the carrier id is known. Only
+// whitespace in the carrier name is replaced (like the CarrierConfig app), so
+// the name must be checked before using it as a path.
+func CarrierIDConfigFileName(carrierID int32, carrierName string) string {
+	return "carrier_config_carrierid_" + strconv.Itoa(int(carrierID)) + "_" + configFileNameSpace.ReplaceAllLiteralString(carrierName, "-") + ".xml"
+}
+
+// MCCMNCConfigFileName returns the name of the config file for a mccmnc, which
+// is used by the CarrierConfig app if there isn't a file for the carrier id.
+// The carrier_config elements in it are filtered by the mvno attributes.
+func MCCMNCConfigFileName(mccmnc string) string {
+	return "carrier_config_mccmnc_" + mccmnc + ".xml"
+}
+
+// ConfigFile is a carrier config file.
+type ConfigFile struct {
+	Name    string
+	Configs []ConfigEntry
+}
+
+// ConfigEntry is a carrier_config element. The CarrierConfig app merges all
+// matching entries in a file, with later ones overriding earlier ones.
+type ConfigEntry struct {
+	Comment string
+	Filter  ConfigFilter
+	Config  *carrier_settings.CarrierConfig
+}
+
+// ConfigFilter contains the attributes DefaultCarrierConfigService uses to
+// filter carrier_config elements. Empty fields are ignored.
+type ConfigFilter struct {
+	GID1 string // case-insensitive
+	SPN  string // case-insensitive
+	IMSI string // regexp
+}
+
+// ConfigFilterFor returns the filter for the mvno data of a carrier list entry.
+func ConfigFilterFor(c *carrier_list.CarrierId) ConfigFilter {
+	var f ConfigFilter
+	switch v := c.GetMvnoData().(type) {
+	case *carrier_list.CarrierId_Spn:
+		f.SPN = v.Spn
+	case *carrier_list.CarrierId_Gid1:
+		f.GID1 = v.Gid1
+	case *carrier_list.CarrierId_Imsi:
+		var b strings.Builder
+		for _, c := range v.Imsi {
+			if c == 'x' || c == 'X' {
+				b.WriteString(`\d`)
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		b.WriteString(`.*`)
+		f.IMSI = b.String()
+	}
+	return f
+}
+
+func (f ConfigFilter) attrs() [][2]string {
+	var a [][2]string
+	if f.GID1 != "" {
+		a = append(a, [2]string{"gid1", f.GID1})
+	}
+	if f.SPN != "" {
+		a = append(a, [2]string{"spn", f.SPN})
+	}
+	if f.IMSI != "" {
+		a = append(a, [2]string{"imsi", f.IMSI})
+	}
+	return a
+}
+
+// ConfigFiles groups the carrier configs in db into files like
+// CarrierConfigConverterV2. Carriers matched to carrier ids (carrierIDs is
+// keyed by canonical name) are written to a carrier id file for each one.
+// Other carriers are written to the mccmnc file for each of their carrier list
+// entries, filtered by the mvno data, after the unfiltered entries for the
+// mccmnc. Carriers without configs are skipped. The files are sorted by name.
+func (db *DB) ConfigFiles(carrierIDs map[string][]*carrierid.CarrierId) []ConfigFile {
+	var (
+		files   = map[string]*ConfigFile{}
+		carrier = db.CarrierMap()
+	)
+	add := func(name string, e ConfigEntry) {
+		f, ok := files[name]
+		if !ok {
+			f = &ConfigFile{Name: name}
+			files[name] = f
+		}
+		f.Configs = append(f.Configs, e)
+	}
+	for _, canonicalName := range slices.Sorted(maps.Keys(db.Settings)) {
+		cfg := db.Settings[canonicalName].GetConfigs()
+		if len(cfg.GetConfig()) == 0 {
+			continue
+		}
+		if cids := carrierIDs[canonicalName]; len(cids) != 0 {
+			for _, c := range cids {
+				add(CarrierIDConfigFileName(c.GetCanonicalId(), c.GetCarrierName()), ConfigEntry{
+					Comment: canonicalName,
+					Config:  cfg,
+				})
+			}
+			continue
+		}
+		for _, cm := range carrier[canonicalName] {
+			for _, c := range cm.GetCarrierId() {
+				if c.GetMccMnc() == "" {
+					continue
+				}
+				add(MCCMNCConfigFileName(c.GetMccMnc()), ConfigEntry{
+					Comment: canonicalName,
+					Filter:  ConfigFilterFor(c),
+					Config:  cfg,
+				})
+			}
+		}
+	}
+	var fs []ConfigFile
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		f := files[name]
+		slices.SortStableFunc(f.Configs, func(a, b ConfigEntry) int {
+			switch x, y := a.Filter == (ConfigFilter{}), b.Filter == (ConfigFilter{}); {
+			case x && !y:
+				return -1
+			case !x && y:
+				return 1
+			default:
+				return 0
+			}
+		})
+		fs = append(fs, *f)
+	}
+	return fs
+}
+
+// Encode writes f as a carrier_config_list document.
+func (f ConfigFile) Encode(w io.Writer) error {
+	x := xmlwriter.New(w)
+	x.Indent("  ")
+	x.DefaultProcInst()
+	x.Start(nil, "carrier_config_list", xmlwriter.NS("").Bind(""))
+	for _, e := range f.Configs {
+		if e.Comment != "" {
+			x.Comment(true, " "+e.Comment+" ")
+		}
+		x.Start(nil, "carrier_config")
+		for _, a := range e.Filter.attrs() {
+			x.Attr(nil, a[0], a[1])
+		}
+		if err := encodeBundle(x, e.Config); err != nil {
+			return fmt.Errorf("encode %s: %w", f.Name, err)
+		}
+		x.End(false)
+	}
+	x.End(false)
+	return x.Close()
+}
+
+// encodeBundle writes the values of cfg as PersistableBundle elements.
+func encodeBundle(x *xmlwriter.XMLWriter, cfg *carrier_settings.CarrierConfig) error {
+	for _, c := range cfg.GetConfig() {
+		switch v := c.GetValue().(type) {
+		case *carrier_settings.CarrierConfig_Config_TextValue:
+			x.Start(nil, "string")
+			x.Attr(nil, "name", c.GetKey())
+			x.Text(false, v.TextValue)
+			x.End(false)
+		case *carrier_settings.CarrierConfig_Config_IntValue:
+			x.Start(nil, "int")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "value", strconv.FormatInt(int64(v.IntValue), 10))
+			x.End(true)
+		case *carrier_settings.CarrierConfig_Config_LongValue:
+			x.Start(nil, "long")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "value", strconv.FormatInt(v.LongValue, 10))
+			x.End(true)
+		case *carrier_settings.CarrierConfig_Config_BoolValue:
+			x.Start(nil, "boolean")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "value", strconv.FormatBool(v.BoolValue))
+			x.End(true)
+		case *carrier_settings.CarrierConfig_Config_DoubleValue:
+			x.Start(nil, "double")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "value", formatDouble(v.DoubleValue))
+			x.End(true)
+		case *carrier_settings.CarrierConfig_Config_TextArray:
+			x.Start(nil, "string-array")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "num", strconv.Itoa(len(v.TextArray.GetItem())))
+			for _, item := range v.TextArray.GetItem() {
+				x.Start(nil, "item")
+				x.Attr(nil, "value", item)
+				x.End(true)
+			}
+			x.End(false)
+		case *carrier_settings.CarrierConfig_Config_IntArray:
+			x.Start(nil, "int-array")
+			x.Attr(nil, "name", c.GetKey())
+			x.Attr(nil, "num", strconv.Itoa(len(v.IntArray.GetItem())))
+			for _, item := range v.IntArray.GetItem() {
+				x.Start(nil, "item")
+				x.Attr(nil, "value", strconv.FormatInt(int64(item), 10))
+				x.End(true)
+			}
+			x.End(false)
+		case *carrier_settings.CarrierConfig_Config_Bundle:
+			x.Start(nil, "pbundle_as_map")
+			x.Attr(nil, "name", c.GetKey())
+			if err := encodeBundle(x, v.Bundle); err != nil {
+				return fmt.Errorf("%s: %w", c.GetKey(), err)
+			}
+			x.End(false)
+		case nil:
+			return fmt.Errorf("%s: missing value", c.GetKey())
+		default:
+			return fmt.Errorf("%s: unhandled value type %T", c.GetKey(), v)
+		}
+	}
+	return x.Err()
+}
+
+// formatDouble formats v so it looks like Java's Double.toString, which is
+// what XmlSerializer uses.
+func formatDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,434 @@
+package carriersettings
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+)
+
+// https://cs.android.com/android/platform/superproject/main/+/main:frameworks/base/telephony/java/android/telephony/CarrierConfigManager.java
+
+// ConfigType is the type of a carrier config value.
+type ConfigType int
+
+const (
+	ConfigTypeNone ConfigType = iota // missing value
+	ConfigTypeText
+	ConfigTypeInt
+	ConfigTypeLong
+	ConfigTypeBool
+	ConfigTypeTextArray
+	ConfigTypeIntArray
+	ConfigTypeBundle
+	ConfigTypeDouble
+)
+
+func (x ConfigType) Valid() bool {
+	return x.String() != ""
+}
+
+// String returns the name of the CarrierConfig.Config oneof field.
+func (x ConfigType) String() string {
+	switch x {
+	case ConfigTypeNone:
+		return "none"
+	case ConfigTypeText:
+		return "text_value"
+	case ConfigTypeInt:
+		return "int_value"
+	case ConfigTypeLong:
+		return "long_value"
+	case ConfigTypeBool:
+		return "bool_value"
+	case ConfigTypeTextArray:
+		return "text_array"
+	case ConfigTypeIntArray:
+		return "int_array"
+	case ConfigTypeBundle:
+		return "bundle"
+	case ConfigTypeDouble:
+		return "double_value"
+	default:
+		return ""
+	}
+}
+
+// ConfigTypeOf returns the type of the value of c.
+func ConfigTypeOf(c *carrier_settings.CarrierConfig_Config) ConfigType {
+	switch c.GetValue().(type) {
+	case *carrier_settings.CarrierConfig_Config_TextValue:
+		return ConfigTypeText
+	case *carrier_settings.CarrierConfig_Config_IntValue:
+		return ConfigTypeInt
+	case *carrier_settings.CarrierConfig_Config_LongValue:
+		return ConfigTypeLong
+	case *carrier_settings.CarrierConfig_Config_BoolValue:
+		return ConfigTypeBool
+	case *carrier_settings.CarrierConfig_Config_TextArray:
+		return ConfigTypeTextArray
+	case *carrier_settings.CarrierConfig_Config_IntArray:
+		return ConfigTypeIntArray
+	case *carrier_settings.CarrierConfig_Config_Bundle:
+		return ConfigTypeBundle
+	case *carrier_settings.CarrierConfig_Config_DoubleValue:
+		return ConfigTypeDouble
+	default:
+		return ConfigTypeNone
+	}
+}
+
+// configValue returns the value of c with the same type as ConfigKey.Default.
+func configValue(c *carrier_settings.CarrierConfig_Config) any {
+	switch v := c.GetValue().(type) {
+	case *carrier_settings.CarrierConfig_Config_TextValue:
+		return v.TextValue
+	case *carrier_settings.CarrierConfig_Config_IntValue:
+		return v.IntValue
+	case *carrier_settings.CarrierConfig_Config_LongValue:
+		return v.LongValue
+	case *carrier_settings.CarrierConfig_Config_BoolValue:
+		return v.BoolValue
+	case *carrier_settings.CarrierConfig_Config_TextArray:
+		return v.TextArray.GetItem()
+	case *carrier_settings.CarrierConfig_Config_IntArray:
+		return v.IntArray.GetItem()
+	case *carrier_settings.CarrierConfig_Config_DoubleValue:
+		return v.DoubleValue
+	default:
+		return nil
+	}
+}
+
+// ConfigKey is a CarrierConfigManager key.
+type ConfigKey struct {
+	Name string
+	Type ConfigType
+
+	// Default is the platform default from CarrierConfigManager.sDefaults. It
+	// is a string, int32, int64, bool, []string, []int32, or float64 depending
+	// on the type. It is nil only for bundles, and for arrays and strings which
+	// default to null.
+	Default any
+
+	// SDK is the API level the key was added to the public API.
+	SDK int
+}
+
+// IsDefault returns true if c has the same type and value as the default.
+func (k ConfigKey) IsDefault(c *carrier_settings.CarrierConfig_Config) bool {
+	if ConfigTypeOf(c) != k.Type || k.Default == nil {
+		return false
+	}
+	switch d := k.Default.(type) {
+	case []string:
+		return slices.Equal(d, configValue(c).([]string))
+	case []int32:
+		return slices.Equal(d, configValue(c).([]int32))
+	default:
+		return d == configValue(c)
+	}
+}
+
+// configKeyPrefixes are the prefixes used by the nested CarrierConfigManager
+// classes. Keys with a '.' and a different prefix are vendor keys.
+var configKeyPrefixes = []string{
+	"apn.",
+	"bsf.",
+	"gps.",
+	"ims.",
+	"imsemergency.",
+	"imsrtt.",
+	"imsserviceentitlement.",
+	"imssms.",
+	"imsss.",
+	"imsvoice.",
+	"imsvt.",
+	"imswfc.",
+	"iwlan.",
+	"wifi.",
+}
+
+// configKeys contains known keys. It is not complete, and keys should be added
+// as they are needed (e.g., when ValidateConfig reports them as unknown), but
+// only with the default and API level checked against CarrierConfigManager.
+var configKeys = func() map[string]ConfigKey {
+	m := map[string]ConfigKey{}
+	for _, k := range []ConfigKey{
+		// general
+		{"carrier_config_version_string", ConfigTypeText, nil, 29},
+		{"carrier_settings_enable_bool", ConfigTypeBool, false, 23},
+		{"carrier_name_override_bool", ConfigTypeBool, false, 23},
+		{"carrier_name_string", ConfigTypeText, "", 23},
+		{"ci_action_on_sys_update_bool", ConfigTypeBool, false, 23},
+		{"csp_enabled_bool", ConfigTypeBool, false, 23},
+		{"world_phone_bool", ConfigTypeBool, false, 23},
+		{"require_entitlement_checks_bool", ConfigTypeBool, true, 23},
+		{"hide_sim_lock_settings_bool", ConfigTypeBool, false, 23},
+		{"hide_carrier_network_settings_bool", ConfigTypeBool, false, 23},
+		{"operator_selection_expand_bool", ConfigTypeBool, true, 23},
+		{"prefer_2g_bool", ConfigTypeBool, true, 23},
+		{"auto_retry_enabled_bool", ConfigTypeBool, false, 23},
+		{"show_apn_setting_cdma_bool", ConfigTypeBool, false, 23},
+		{"show_cdma_choices_bool", ConfigTypeBool, false, 23},
+		{"show_onscreen_dial_button_bool", ConfigTypeBool, true, 23},
+		{"disable_cdma_activation_code_bool", ConfigTypeBool, false, 23},
+		{"carrier_nr_availabilities_int_array", ConfigTypeIntArray, []int32{1, 2}, 31},
+
+		// apns
+		{"apn_expand_bool", ConfigTypeBool, true, 23},
+		{"allow_adding_apns_bool", ConfigTypeBool, true, 23},
+		{"hide_ims_apn_bool", ConfigTypeBool, false, 23},
+		{"read_only_apn_types_string_array", ConfigTypeTextArray, []string{"dun"}, 28},
+
+		// calling
+		{"carrier_volte_available_bool", ConfigTypeBool, false, 23},
+		{"carrier_volte_provisioning_required_bool", ConfigTypeBool, false, 23},
+		{"carrier_vt_available_bool", ConfigTypeBool, false, 23},
+		{"carrier_wfc_ims_available_bool", ConfigTypeBool, false, 23},
+		{"carrier_wfc_supports_wifi_only_bool", ConfigTypeBool, false, 23},
+		{"carrier_allow_turnoff_ims_bool", ConfigTypeBool, true, 23},
+		{"carrier_use_ims_first_for_emergency_bool", ConfigTypeBool, true, 23},
+		{"carrier_instant_lettering_available_bool", ConfigTypeBool, false, 23},
+		{"carrier_supports_ss_over_ut_bool", ConfigTypeBool, false, 28},
+		{"carrier_ut_provisioning_required_bool", ConfigTypeBool, false, 28},
+		{"carrier_cross_sim_ims_available_bool", ConfigTypeBool, false, 31},
+		{"enable_cross_sim_calling_on_opportunistic_data_bool", ConfigTypeBool, false, 31},
+		{"vonr_enabled_bool", ConfigTypeBool, false, 31},
+		{"vonr_setting_visibility_bool", ConfigTypeBool, true, 31},
+		{"support_conference_call_bool", ConfigTypeBool, true, 23},
+		{"support_ims_conference_call_bool", ConfigTypeBool, true, 23},
+		{"support_video_conference_call_bool", ConfigTypeBool, false, 23},
+		{"ims_conference_size_limit_int", ConfigTypeInt, int32(5), 26},
+		{"dtmf_type_enabled_bool", ConfigTypeBool, false, 23},
+		{"enable_dialer_key_vibration_bool", ConfigTypeBool, true, 23},
+		{"has_in_call_noise_suppression_bool", ConfigTypeBool, false, 23},
+		{"mdn_is_additional_voicemail_number_bool", ConfigTypeBool, false, 23},
+		{"carrier_force_disable_etws_cmas_test_bool", ConfigTypeBool, false, 23},
+
+		// voicemail
+		{"vvm_type_string", ConfigTypeText, "", 23},
+		{"vvm_destination_number_string", ConfigTypeText, "", 23},
+		{"vvm_port_number_int", ConfigTypeInt, int32(0), 23},
+
+		// data usage
+		{"monthly_data_cycle_day_int", ConfigTypeInt, int32(-1), 28},
+		{"data_warning_threshold_bytes_long", ConfigTypeLong, int64(-1), 28},
+		{"data_limit_threshold_bytes_long", ConfigTypeLong, int64(-1), 28},
+
+		// signal strength
+		{"lte_rsrp_thresholds_int_array", ConfigTypeIntArray, []int32{-128, -118, -108, -98}, 28},
+		{"lte_rsrq_thresholds_int_array", ConfigTypeIntArray, []int32{-19, -17, -14, -12}, 30},
+		{"lte_rssnr_thresholds_int_array", ConfigTypeIntArray, []int32{-3, 1, 5, 13}, 30},
+		{"wcdma_rscp_thresholds_int_array", ConfigTypeIntArray, []int32{-115, -105, -95, -85}, 29},
+		{"gsm_rssi_thresholds_int_array", ConfigTypeIntArray, []int32{-107, -103, -97, -89}, 30},
+		{"5g_nr_ssrsrp_thresholds_int_array", ConfigTypeIntArray, []int32{-110, -90, -80, -65}, 30},
+		{"5g_nr_ssrsrq_thresholds_int_array", ConfigTypeIntArray, []int32{-31, -19, -7, 6}, 30},
+		{"5g_nr_sssinr_thresholds_int_array", ConfigTypeIntArray, []int32{-5, 5, 15, 30}, 30},
+		{"parameters_used_for_lte_signal_bar_int", ConfigTypeInt, int32(1), 30},
+		{"parameters_use_for_5g_nr_signal_bar_int", ConfigTypeInt, int32(1), 30},
+
+		// ims
+		{"ims.wifi_off_deferring_time_millis_int", ConfigTypeInt, int32(4000), 31},
+		{"ims.ims_single_registration_required_bool", ConfigTypeBool, false, 31},
+		{"ims.enable_presence_publish_bool", ConfigTypeBool, false, 31},
+		{"ims.mmtel_requires_provisioning_bundle", ConfigTypeBundle, nil, 31},
+
+		// mms (these don't follow the naming convention)
+		{"enabledMMS", ConfigTypeBool, true, 23},
+		{"enableGroupMms", ConfigTypeBool, true, 23},
+		{"enableMultipartSMS", ConfigTypeBool, true, 23},
+		{"enableSMSDeliveryReports", ConfigTypeBool, true, 23},
+		{"enableMMSDeliveryReports", ConfigTypeBool, false, 23},
+		{"enableMMSReadReports", ConfigTypeBool, false, 23},
+		{"enabledNotifyWapMMSC", ConfigTypeBool, false, 23},
+		{"enabledTransID", ConfigTypeBool, false, 23},
+		{"aliasEnabled", ConfigTypeBool, false, 23},
+		{"aliasMinChars", ConfigTypeInt, int32(2), 23},
+		{"aliasMaxChars", ConfigTypeInt, int32(48), 23},
+		{"allowAttachAudio", ConfigTypeBool, true, 23},
+		{"supportMmsContentDisposition", ConfigTypeBool, true, 23},
+		{"supportHttpCharsetHeader", ConfigTypeBool, false, 23},
+		{"sendMultipartSmsAsSeparateMessages", ConfigTypeBool, false, 23},
+		{"mmsCloseConnection", ConfigTypeBool, false, 28},
+		{"maxMessageSize", ConfigTypeInt, int32(300 * 1024), 23},
+		{"maxImageWidth", ConfigTypeInt, int32(640), 23},
+		{"maxImageHeight", ConfigTypeInt, int32(480), 23},
+		{"maxMessageTextSize", ConfigTypeInt, int32(-1), 23},
+		{"maxSubjectLength", ConfigTypeInt, int32(40), 23},
+		{"recipientLimit", ConfigTypeInt, int32(math.MaxInt32), 23},
+		{"httpSocketTimeout", ConfigTypeInt, int32(60 * 1000), 23},
+		{"smsToMmsTextThreshold", ConfigTypeInt, int32(-1), 23},
+		{"smsToMmsTextLengthThreshold", ConfigTypeInt, int32(-1), 23},
+		{"userAgent", ConfigTypeText, "", 23},
+		{"uaProfUrl", ConfigTypeText, "", 23},
+		{"uaProfTagName", ConfigTypeText, "x-wap-profile", 23},
+		{"httpParams", ConfigTypeText, "", 23},
+		{"emailGatewayNumber", ConfigTypeText, "", 23},
+		{"naiSuffix", ConfigTypeText, "", 23},
+	} {
+		if _, ok := m[k.Name]; ok {
+			panic("duplicate config key " + k.Name)
+		}
+		if k.SDK == 0 {
+			panic("missing api level for config key " + k.Name)
+		}
+		m[k.Name] = k
+	}
+	return m
+}()
+
+// LookupConfigKey returns the known key with the specified name.
+func LookupConfigKey(name string) (ConfigKey, bool) {
+	k, ok := configKeys[name]
+	return k, ok
+}
+
+// ConfigKeys returns the known keys sorted by name.
+func ConfigKeys() []ConfigKey {
+	ks := make([]ConfigKey, 0, len(configKeys))
+	for _, name := range slices.Sorted(maps.Keys(configKeys)) {
+		ks = append(ks, configKeys[name])
+	}
+	return ks
+}
+
+// IsVendorConfigKey returns true if name is namespaced (i.e., contains a '.')
+// but doesn't use one of the CarrierConfigManager prefixes.
+func IsVendorConfigKey(name string) bool {
+	if !strings.Contains(name, ".") {
+		return false
+	}
+	for _, p := range configKeyPrefixes {
+		if strings.HasPrefix(name, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConfigTypeFromSuffix returns the type implied by the suffix of a key name,
+// following the CarrierConfigManager naming convention.
+func ConfigTypeFromSuffix(name string) (ConfigType, bool) {
+	switch {
+	case strings.HasSuffix(name, "_string_array"), strings.HasSuffix(name, "_strings"):
+		return ConfigTypeTextArray, true
+	case strings.HasSuffix(name, "_int_array"), strings.HasSuffix(name, "_ints"):
+		return ConfigTypeIntArray, true
+	case strings.HasSuffix(name, "_string"):
+		return ConfigTypeText, true
+	case strings.HasSuffix(name, "_int"):
+		return ConfigTypeInt, true
+	case strings.HasSuffix(name, "_long"):
+		return ConfigTypeLong, true
+	case strings.HasSuffix(name, "_bool"):
+		return ConfigTypeBool, true
+	case strings.HasSuffix(name, "_bundle"):
+		return ConfigTypeBundle, true
+	case strings.HasSuffix(name, "_double"):
+		return ConfigTypeDouble, true
+	default:
+		return ConfigTypeNone, false
+	}
+}
+
+// ConfigIssueKind is the kind of problem found by ValidateConfig.
+type ConfigIssueKind int
+
+const (
+	ConfigIssueUnknown      ConfigIssueKind = iota // not a known key
+	ConfigIssueVendor                              // vendor key
+	ConfigIssueTypeMismatch                        // wrong value type (ignored by PersistableBundle getters)
+	ConfigIssueDuplicate                           // key set more than once (the last one wins)
+	ConfigIssueDefault                             // same as the platform default
+)
+
+func (x ConfigIssueKind) String() string {
+	switch x {
+	case ConfigIssueUnknown:
+		return "unknown"
+	case ConfigIssueVendor:
+		return "vendor"
+	case ConfigIssueTypeMismatch:
+		return "type-mismatch"
+	case ConfigIssueDuplicate:
+		return "duplicate"
+	case ConfigIssueDefault:
+		return "default"
+	default:
+		return ""
+	}
+}
+
+// ConfigIssue is a problem with a carrier config entry.
+type ConfigIssue struct {
+	Key     string
+	Kind    ConfigIssueKind
+	Message string
+}
+
+func (i ConfigIssue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", i.Key, i.Message, i.Kind)
+}
+
+// ValidateConfig checks the top-level entries of cfg against the known keys.
+// For unknown keys, the type is checked against the key suffix. Nested bundles
+// are not checked since their keys are defined by the individual configs.
+func ValidateConfig(cfg *carrier_settings.CarrierConfig) []ConfigIssue {
+	var (
+		is   []ConfigIssue
+		seen = map[string]bool{}
+	)
+	for _, c := range cfg.GetConfig() {
+		name, t := c.GetKey(), ConfigTypeOf(c)
+		if seen[name] {
+			is = append(is, ConfigIssue{name, ConfigIssueDuplicate, "key is set more than once"})
+		}
+		seen[name] = true
+
+		k, ok := LookupConfigKey(name)
+		if !ok {
+			if IsVendorConfigKey(name) {
+				is = append(is, ConfigIssue{name, ConfigIssueVendor, "vendor key"})
+				continue
+			}
+			is = append(is, ConfigIssue{name, ConfigIssueUnknown, "unknown key"})
+			if st, ok := ConfigTypeFromSuffix(name); ok && st != t {
+				is = append(is, ConfigIssue{name, ConfigIssueTypeMismatch, fmt.Sprintf("key suffix implies %s, but value is %s", st, t)})
+			}
+			continue
+		}
+		if k.Type != t {
+			is = append(is, ConfigIssue{name, ConfigIssueTypeMismatch, fmt.Sprintf("expected %s, got %s", k.Type, t)})
+			continue
+		}
+		if k.IsDefault(c) {
+			is = append(is, ConfigIssue{name, ConfigIssueDefault, fmt.Sprintf("value %v is the platform default", k.Default)})
+		}
+	}
+	return is
+}
+
+// ConfigOverrides returns the keys of the known entries in cfg with the
+// correct type which differ from the platform default. If a key is set more
+// than once, the last entry is used.
+func ConfigOverrides(cfg *carrier_settings.CarrierConfig) []string {
+	var (
+		ks       []string
+		override = map[string]bool{}
+	)
+	for _, c := range cfg.GetConfig() {
+		if k, ok := LookupConfigKey(c.GetKey()); ok {
+			if _, seen := override[k.Name]; !seen {
+				ks = append(ks, k.Name)
+			}
+			override[k.Name] = k.Type == ConfigTypeOf(c) && !k.IsDefault(c)
+		}
+	}
+	return slices.DeleteFunc(ks, func(k string) bool {
+		return !override[k]
+	})
+}
@@ -0,0 +1,66 @@
+package carriersettings
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidateConfig(t *testing.T) {
+	text := func(k, v string) *carrier_settings.CarrierConfig_Config {
+		return &carrier_settings.CarrierConfig_Config{Key: proto.String(k), Value: &carrier_settings.CarrierConfig_Config_TextValue{TextValue: v}}
+	}
+	boolean := func(k string, v bool) *carrier_settings.CarrierConfig_Config {
+		return &carrier_settings.CarrierConfig_Config{Key: proto.String(k), Value: &carrier_settings.CarrierConfig_Config_BoolValue{BoolValue: v}}
+	}
+	integer := func(k string, v int32) *carrier_settings.CarrierConfig_Config {
+		return &carrier_settings.CarrierConfig_Config{Key: proto.String(k), Value: &carrier_settings.CarrierConfig_Config_IntValue{IntValue: v}}
+	}
+	cfg := &carrier_settings.CarrierConfig{
+		Config: []*carrier_settings.CarrierConfig_Config{
+			boolean("carrier_volte_available_bool", true),              // override
+			boolean("carrier_vt_available_bool", false),                // default
+			integer("ims_conference_size_limit_int", 5),                // default
+			text("carrier_name_string", "Test"),                        // override
+			integer("vvm_port_number_int", 1),                          // set twice, last one is the default
+			integer("vvm_port_number_int", 0),                          //
+			text("monthly_data_cycle_day_int", "1"),                    // type mismatch
+			boolean("not_a_real_key_bool", true),                       // unknown
+			integer("not_a_real_key_string", 1),                        // unknown and suffix mismatch
+			boolean("com.example.vendor_bool", true),                   // vendor
+			boolean("ims.ims_single_registration_required_bool", true), // override with prefix
+		},
+	}
+
+	type issue struct {
+		Key  string
+		Kind ConfigIssueKind
+	}
+	var got []issue
+	for _, is := range ValidateConfig(cfg) {
+		got = append(got, issue{is.Key, is.Kind})
+	}
+	if want := []issue{
+		{"carrier_vt_available_bool", ConfigIssueDefault},
+		{"ims_conference_size_limit_int", ConfigIssueDefault},
+		{"vvm_port_number_int", ConfigIssueDuplicate},
+		{"vvm_port_number_int", ConfigIssueDefault},
+		{"monthly_data_cycle_day_int", ConfigIssueTypeMismatch},
+		{"not_a_real_key_bool", ConfigIssueUnknown},
+		{"not_a_real_key_string", ConfigIssueUnknown},
+		{"not_a_real_key_string", ConfigIssueTypeMismatch},
+		{"com.example.vendor_bool", ConfigIssueVendor},
+	}; !slices.Equal(got, want) {
+		t.Errorf("incorrect issues:\nexpected %v\n     got %v", want, got)
+	}
+
+	if want, got := []string{
+		"carrier_volte_available_bool",
+		"carrier_name_string",
+		"ims.ims_single_registration_required_bool",
+	}, ConfigOverrides(cfg); !slices.Equal(got, want) {
+		t.Errorf("incorrect overrides:\nexpected %q\n     got %q", want, got)
+	}
+}
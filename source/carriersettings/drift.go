@@ -0,0 +1,161 @@
+package carriersettings
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxUnknownFieldExamples is the maximum number of examples kept for each
+// UnknownField.
+const maxUnknownFieldExamples = 5
+
+// UnknownField is a field which isn't in the schema, usually because it was
+// added in a newer version of the protos.
+type UnknownField struct {
+	Message  protoreflect.FullName `json:"message"` // containing message
+	Number   protowire.Number      `json:"number"`
+	WireType string                `json:"wire_type"` // varint, fixed32, fixed64, bytes, group, or invalid
+	Count    int                   `json:"count"`     // number of occurrences
+	Examples []string              `json:"examples"`  // canonical names (or file names) of the first few occurrences
+
+	// Value is the value of the first occurrence, decoded without a schema
+	// (see DecodeWire).
+	Value any `json:"value"`
+}
+
+// UnknownFields collects the unknown fields in protobuf messages.
+type UnknownFields struct {
+	fields map[unknownFieldKey]*UnknownField
+}
+
+type unknownFieldKey struct {
+	message  protoreflect.FullName
+	number   protowire.Number
+	wireType string
+}
+
+// Scan adds the unknown fields in m and its submessages, using example to
+// identify where they came from.
+func (u *UnknownFields) Scan(m proto.Message, example string) {
+	u.scan(m.ProtoReflect(), example)
+}
+
+func (u *UnknownFields) scan(m protoreflect.Message, example string) {
+	u.scanRaw(m.Descriptor().FullName(), m.GetUnknown(), example)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+					u.scan(v.Message(), example)
+					return true
+				})
+			}
+		case fd.IsList():
+			if fd.Message() != nil {
+				for i := range v.List().Len() {
+					u.scan(v.List().Get(i).Message(), example)
+				}
+			}
+		case fd.Message() != nil:
+			u.scan(v.Message(), example)
+		}
+		return true
+	})
+}
+
+func (u *UnknownFields) scanRaw(message protoreflect.FullName, b protoreflect.RawFields, example string) {
+	for len(b) != 0 {
+		k := unknownFieldKey{message: message}
+		num, typ, n := protowire.ConsumeField(b)
+		if n < 0 {
+			// we can't find where the next field starts, so keep the rest
+			k.wireType, n = "invalid", len(b)
+		} else {
+			k.number, k.wireType = num, wireTypeName(typ)
+		}
+		raw := b[:n]
+		b = b[n:]
+
+		if u.fields == nil {
+			u.fields = map[unknownFieldKey]*UnknownField{}
+		}
+		f, ok := u.fields[k]
+		if !ok {
+			f = &UnknownField{
+				Message:  k.message,
+				Number:   k.number,
+				WireType: k.wireType,
+				Value:    []byte(raw),
+			}
+			if v, err := DecodeWire(raw); err == nil && len(v) == 1 {
+				f.Value = v[0].Value
+			}
+			u.fields[k] = f
+		}
+		f.Count++
+		if len(f.Examples) < maxUnknownFieldExamples && !slices.Contains(f.Examples, example) {
+			f.Examples = append(f.Examples, example)
+		}
+	}
+}
+
+// Fields returns the unknown fields sorted by message, number, and wire type.
+func (u *UnknownFields) Fields() []UnknownField {
+	fs := make([]UnknownField, 0, len(u.fields))
+	for _, k := range slices.SortedFunc(maps.Keys(u.fields), func(a, b unknownFieldKey) int {
+		return cmp.Or(
+			cmp.Compare(a.message, b.message),
+			cmp.Compare(a.number, b.number),
+			cmp.Compare(a.wireType, b.wireType),
+		)
+	}) {
+		fs = append(fs, *u.fields[k])
+	}
+	return fs
+}
+
+// UnknownFields scans every loaded file (see DB.Files) for unknown fields,
+// including settings which were overwritten or merged while loading. Settings
+// are identified by their canonical name, and the other messages by their file
+// name.
+func (db *DB) UnknownFields() []UnknownField {
+	var u UnknownFields
+	for _, name := range slices.Sorted(maps.Keys(db.Files)) {
+		switch m := db.Files[name].(type) {
+		case *carrier_settings.MultiCarrierSettings:
+			u.scanRaw(m.ProtoReflect().Descriptor().FullName(), m.ProtoReflect().GetUnknown(), name)
+			for _, cs := range m.GetSetting() {
+				u.Scan(cs, cmp.Or(cs.GetCanonicalName(), name))
+			}
+		case *carrier_settings.CarrierSettings:
+			u.Scan(m, cmp.Or(m.GetCanonicalName(), name))
+		default:
+			u.Scan(m, name)
+		}
+	}
+	return u.Fields()
+}
+
+func wireTypeName(t protowire.Type) string {
+	switch t {
+	case protowire.VarintType:
+		return "varint"
+	case protowire.Fixed32Type:
+		return "fixed32"
+	case protowire.Fixed64Type:
+		return "fixed64"
+	case protowire.BytesType:
+		return "bytes"
+	case protowire.StartGroupType:
+		return "group"
+	default:
+		return "invalid"
+	}
+}
@@ -0,0 +1,669 @@
+package carriersettings
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Format is a protobuf encoding.
+type Format int
+
+const (
+	FormatBinary Format = iota // binary wire format
+	FormatText                 // textproto
+	FormatJSON                 // protojson
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatBinary:
+		return "binary"
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	default:
+		return strconv.Itoa(int(f))
+	}
+}
+
+// formatExts contains the file extensions for each format, in the order they
+// are looked for.
+var formatExts = []struct {
+	Ext    string
+	Format Format
+}{
+	{".pb", FormatBinary},
+	{".binpb", FormatBinary},
+	{".textpb", FormatText},
+	{".txtpb", FormatText},
+	{".textproto", FormatText},
+	{".pbtxt", FormatText},
+	{".json", FormatJSON},
+}
+
+// FormatFromExt returns the format for a file extension.
+func FormatFromExt(ext string) (Format, bool) {
+	for _, x := range formatExts {
+		if strings.EqualFold(x.Ext, ext) {
+			return x.Format, true
+		}
+	}
+	return 0, false
+}
+
+// DetectFormat detects the format of a protobuf file from its extension, or if
+// it isn't a known one, from its contents.
+func DetectFormat(name string, b []byte) Format {
+	if f, ok := FormatFromExt(path.Ext(name)); ok {
+		return f
+	}
+	if !utf8.Valid(b) {
+		return FormatBinary
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return FormatBinary
+		}
+	}
+	if t := bytes.TrimSpace(b); len(t) == 0 {
+		return FormatBinary
+	} else if t[0] == '{' {
+		return FormatJSON // a textproto message can't start with a brace
+	}
+	return FormatText
+}
+
+// UnmarshalProto decodes b into m using the format detected from name and b
+// (see DetectFormat).
+//
+// Unlike prototext.Unmarshal, unknown fields in textproto (written with
+// prototext.MarshalOptions.EmitUnknown) are preserved, so text dumps written by
+// MarshalText can be decoded without losing data. Since prototext writes
+// unknown fixed32 and fixed64 fields the same way, an error is returned if the
+// wire type of one can't be determined. protojson cannot represent unknown
+// fields.
+func UnmarshalProto(name string, b []byte, m proto.Message) error {
+	switch f := DetectFormat(name, b); f {
+	case FormatBinary:
+		return proto.Unmarshal(b, m)
+	case FormatText:
+		return unmarshalText(b, m)
+	case FormatJSON:
+		return protojson.Unmarshal(b, m)
+	default:
+		return fmt.Errorf("unsupported format %s", f)
+	}
+}
+
+// unmarshalText decodes textproto, preserving unknown fields. Since prototext
+// rejects them, the text is parsed into a tree, the fields with numeric names
+// are removed, the rest is decoded with prototext, then the unknown fields are
+// encoded and added to the corresponding messages.
+func unmarshalText(b []byte, m proto.Message) error {
+	t := &textParser{s: string(b)}
+	fs, err := t.message("")
+	if err != nil {
+		return err
+	}
+	if !hasUnknownText(fs) {
+		return prototext.Unmarshal(b, m)
+	}
+
+	var buf strings.Builder
+	writeText(&buf, fs)
+	if err := prototext.Unmarshal([]byte(buf.String()), m); err != nil {
+		return err
+	}
+	if err := addUnknownText(m.ProtoReflect(), fs); err != nil {
+		return err
+	}
+
+	// re-decode it so numeric fields which are actually known get parsed
+	raw, err := proto.MarshalOptions{AllowPartial: true}.Marshal(m)
+	if err != nil {
+		return err
+	}
+	proto.Reset(m)
+	return proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(raw, m)
+}
+
+// textField is a parsed textproto field.
+type textField struct {
+	Name   string
+	Number protowire.Number // if the name is numeric
+	Value  textValue
+}
+
+// textValue is a parsed textproto value. Exactly one of Scalar, Message, and
+// List is set.
+type textValue struct {
+	Scalar  []string    // raw tokens (multiple for concatenated strings)
+	Message []textField // message or group
+	List    []textValue
+
+	End     int    // offset of the end of the last scalar token
+	Comment string // comment on the same line after a non-string scalar
+}
+
+type textParser struct {
+	s   string
+	pos int
+}
+
+func (t *textParser) errorf(format string, a ...any) error {
+	line := 1 + strings.Count(t.s[:t.pos], "\n")
+	return fmt.Errorf("parse textproto: line %d: %s", line, fmt.Sprintf(format, a...))
+}
+
+// next returns the next token without consuming it.
+func (t *textParser) next() string {
+	for t.pos < len(t.s) {
+		switch c := t.s[t.pos]; {
+		case c == '#':
+			if i := strings.IndexByte(t.s[t.pos:], '\n'); i != -1 {
+				t.pos += i
+			} else {
+				t.pos = len(t.s)
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f':
+			t.pos++
+		default:
+			return t.token()
+		}
+	}
+	return ""
+}
+
+func (t *textParser) token() string {
+	s := t.s[t.pos:]
+	switch c := s[0]; {
+	case strings.IndexByte("{}<>[]:,;", c) != -1:
+		return s[:1]
+	case c == '"' || c == '\'':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case c:
+				return s[:i+1]
+			case '\n':
+				return s[:i]
+			}
+		}
+		return s
+	default:
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return !(r == '_' || r == '.' || r == '-' || r == '+' || r == '/' || unicode.IsLetter(r) || unicode.IsDigit(r))
+		})
+		if i == -1 {
+			i = len(s)
+		} else if i == 0 {
+			_, i = utf8.DecodeRuneInString(s)
+		}
+		return s[:i]
+	}
+}
+
+// comment returns the comment on the rest of the current line (after an
+// optional separator), if any.
+func (t *textParser) comment() string {
+	s := t.s[t.pos:]
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	if c, ok := strings.CutPrefix(strings.TrimLeft(s, " \t\r,;"), "#"); ok {
+		return strings.TrimSpace(c)
+	}
+	return ""
+}
+
+func (t *textParser) consume() string {
+	tok := t.next()
+	t.pos += len(tok)
+	return tok
+}
+
+// message parses fields until end (or EOF if end is empty).
+func (t *textParser) message(end string) ([]textField, error) {
+	fs := []textField{} // non-nil, even if empty
+	for {
+		switch tok := t.next(); tok {
+		case end:
+			t.consume()
+			return fs, nil
+		case "":
+			return nil, t.errorf("unexpected eof")
+		}
+
+		var f textField
+		if tok := t.consume(); tok == "[" {
+			// extension or Any type url
+			var name strings.Builder
+			name.WriteString(tok)
+			for {
+				tok := t.consume()
+				if tok == "" {
+					return nil, t.errorf("unexpected eof")
+				}
+				name.WriteString(tok)
+				if tok == "]" {
+					break
+				}
+			}
+			f.Name = name.String()
+		} else if v, err := strconv.ParseInt(tok, 10, 32); err == nil {
+			if !protowire.Number(v).IsValid() {
+				return nil, t.errorf("invalid field number %s", tok)
+			}
+			f.Name, f.Number = tok, protowire.Number(v)
+		} else if isTextIdent(tok) {
+			f.Name = tok
+		} else {
+			return nil, t.errorf("expected field name, got %q", tok)
+		}
+
+		if t.next() == ":" {
+			t.consume()
+		}
+		v, err := t.value()
+		if err != nil {
+			return nil, err
+		}
+		f.Value = v
+		fs = append(fs, f)
+
+		if tok := t.next(); tok == ";" || tok == "," {
+			t.consume()
+		}
+	}
+}
+
+func (t *textParser) value() (textValue, error) {
+	switch tok := t.next(); tok {
+	case "{":
+		t.consume()
+		fs, err := t.message("}")
+		return textValue{Message: fs}, err
+	case "<":
+		t.consume()
+		fs, err := t.message(">")
+		return textValue{Message: fs}, err
+	case "[":
+		t.consume()
+		var vs []textValue
+		if t.next() == "]" {
+			t.consume()
+			return textValue{List: []textValue{}}, nil
+		}
+		for {
+			v, err := t.value()
+			if err != nil {
+				return textValue{}, err
+			}
+			vs = append(vs, v)
+			switch tok := t.consume(); tok {
+			case ",":
+			case "]":
+				return textValue{List: vs}, nil
+			default:
+				return textValue{}, t.errorf("expected , or ], got %q", tok)
+			}
+		}
+	case "", "}", ">", "]", ":", ",", ";":
+		return textValue{}, t.errorf("expected value, got %q", tok)
+	default:
+		var v textValue
+		for {
+			v.Scalar = append(v.Scalar, t.consume())
+			v.End = t.pos
+			if !isTextString(v.Scalar[0]) {
+				v.Comment = t.comment()
+				return v, nil
+			}
+			if tok := t.next(); !isTextString(tok) {
+				return v, nil
+			}
+		}
+	}
+}
+
+func isTextIdent(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i != 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isTextString(s string) bool {
+	return s != "" && (s[0] == '"' || s[0] == '\'')
+}
+
+// hasUnknownText checks if any field in fs has a numeric name.
+func hasUnknownText(fs []textField) bool {
+	for _, f := range fs {
+		if f.Number != 0 || hasUnknownTextValue(f.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasUnknownTextValue(v textValue) bool {
+	for _, v := range v.List {
+		if hasUnknownTextValue(v) {
+			return true
+		}
+	}
+	return hasUnknownText(v.Message)
+}
+
+// writeText writes fs as textproto, skipping fields with numeric names.
+func writeText(w *strings.Builder, fs []textField) {
+	for _, f := range fs {
+		if f.Number != 0 {
+			continue
+		}
+		w.WriteString(f.Name)
+		if f.Value.Message == nil {
+			w.WriteString(":")
+		}
+		w.WriteString(" ")
+		writeTextValue(w, f.Value)
+		w.WriteString("\n")
+	}
+}
+
+func writeTextValue(w *strings.Builder, v textValue) {
+	switch {
+	case v.Scalar != nil:
+		w.WriteString(strings.Join(v.Scalar, " "))
+	case v.List != nil:
+		w.WriteString("[")
+		for i, v := range v.List {
+			if i != 0 {
+				w.WriteString(", ")
+			}
+			writeTextValue(w, v)
+		}
+		w.WriteString("]")
+	default:
+		w.WriteString("{\n")
+		writeText(w, v.Message)
+		w.WriteString("}")
+	}
+}
+
+// addUnknownText adds the fields in fs with numeric names to the unknown
+// fields of m, recursing into the submessages decoded from fs. Fields in maps
+// are not supported.
+func addUnknownText(m protoreflect.Message, fs []textField) error {
+	var (
+		raw  = m.GetUnknown()
+		idx  = map[protoreflect.Name]int{}
+		desc = m.Descriptor()
+	)
+	for _, f := range fs {
+		if f.Number != 0 {
+			var err error
+			if raw, err = appendUnknownText(raw, f.Number, f.Value); err != nil {
+				return fmt.Errorf("field %d: %w", f.Number, err)
+			}
+			continue
+		}
+		fd := desc.Fields().ByTextName(f.Name)
+		if fd == nil || fd.Message() == nil || fd.IsMap() {
+			continue
+		}
+		vs := []textValue{f.Value}
+		if f.Value.List != nil {
+			vs = f.Value.List
+		}
+		for _, v := range vs {
+			if !hasUnknownTextValue(v) {
+				if fd.IsList() {
+					idx[fd.Name()]++
+				}
+				continue
+			}
+			var sub protoreflect.Message
+			if fd.IsList() {
+				i := idx[fd.Name()]
+				idx[fd.Name()]++
+				sub = m.Mutable(fd).List().Get(i).Message()
+			} else {
+				sub = m.Mutable(fd).Message()
+			}
+			if err := addUnknownText(sub, v.Message); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+	}
+	if len(raw) != 0 {
+		m.SetUnknown(raw)
+	}
+	return nil
+}
+
+// appendUnknownText encodes an unknown field as written by prototext, where
+// varints are decimal, fixed32/fixed64 are hex, bytes are strings, and groups
+// are messages. Since prototext writes fixed32 and fixed64 values the same way,
+// hex values which fit in 32 bits must be followed by a "# fixed32" or
+// "# fixed64" comment (see MarshalText).
+func appendUnknownText(b []byte, num protowire.Number, v textValue) ([]byte, error) {
+	switch {
+	case v.List != nil:
+		for _, v := range v.List {
+			var err error
+			if b, err = appendUnknownText(b, num, v); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case v.Message != nil:
+		var err error
+		b = protowire.AppendTag(b, num, protowire.StartGroupType)
+		for _, f := range v.Message {
+			if f.Number == 0 {
+				return nil, fmt.Errorf("unknown group has named field %q", f.Name)
+			}
+			if b, err = appendUnknownText(b, f.Number, f.Value); err != nil {
+				return nil, err
+			}
+		}
+		return protowire.AppendTag(b, num, protowire.EndGroupType), nil
+	case isTextString(v.Scalar[0]):
+		var s []byte
+		for _, tok := range v.Scalar {
+			x, err := unquoteText(tok)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, x...)
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, s), nil
+	default:
+		tok := v.Scalar[0]
+		if h, ok := strings.CutPrefix(strings.ToLower(tok), "0x"); ok {
+			x, err := strconv.ParseUint(h, 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unknown field value %q", tok)
+			}
+			var typ string
+			if c := strings.Fields(v.Comment); len(c) != 0 {
+				typ = c[0]
+			}
+			switch {
+			case typ == "fixed32":
+				if x > math.MaxUint32 {
+					return nil, fmt.Errorf("unknown field value %q is too large for fixed32", tok)
+				}
+				b = protowire.AppendTag(b, num, protowire.Fixed32Type)
+				return protowire.AppendFixed32(b, uint32(x)), nil
+			case typ == "fixed64" || x > math.MaxUint32:
+				b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+				return protowire.AppendFixed64(b, x), nil
+			default:
+				return nil, fmt.Errorf("unknown field value %q could be fixed32 or fixed64 (add a # fixed32 or # fixed64 comment after it)", tok)
+			}
+		}
+		x, err := strconv.ParseUint(tok, 10, 64)
+		if err != nil {
+			y, err1 := strconv.ParseInt(tok, 10, 64)
+			if err1 != nil {
+				return nil, fmt.Errorf("invalid unknown field value %q", tok)
+			}
+			x = uint64(y)
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, x), nil
+	}
+}
+
+// MarshalText encodes m as multiline textproto using o, including unknown
+// fields. Since prototext writes unknown fixed32 and fixed64 fields the same
+// way, a "# fixed32" or "# fixed64" comment is added after their values so
+// UnmarshalProto can decode them losslessly.
+func MarshalText(o prototext.MarshalOptions, m proto.Message) ([]byte, error) {
+	o.Multiline, o.EmitUnknown = true, true
+	b, err := o.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := (&textParser{s: string(b)}).message("")
+	if err != nil {
+		return nil, err
+	}
+	var as []textAnnotation
+	if err := annotateUnknownText(m.ProtoReflect(), fs, &as); err != nil {
+		return nil, err
+	}
+	for i := len(as) - 1; i >= 0; i-- { // in reverse so the offsets stay valid
+		b = slices.Insert(b, as[i].Offset, []byte(as[i].Text)...)
+	}
+	return b, nil
+}
+
+// textAnnotation is text to insert at an offset.
+type textAnnotation struct {
+	Offset int
+	Text   string
+}
+
+// annotateUnknownText adds annotations for the wire type of the unknown fixed32
+// and fixed64 fields in fs (parsed from the prototext output for m), recursing
+// into the submessages like addUnknownText.
+func annotateUnknownText(m protoreflect.Message, fs []textField, as *[]textAnnotation) error {
+	if err := annotateUnknownRaw(m.GetUnknown(), fs, as); err != nil {
+		return fmt.Errorf("%s: %w", m.Descriptor().FullName(), err)
+	}
+	desc := m.Descriptor()
+	idx := map[protoreflect.Name]int{}
+	for _, f := range fs {
+		if f.Number != 0 {
+			continue
+		}
+		fd := desc.Fields().ByTextName(f.Name)
+		if fd == nil || fd.Message() == nil || fd.IsMap() {
+			continue
+		}
+		vs := []textValue{f.Value}
+		if f.Value.List != nil {
+			vs = f.Value.List
+		}
+		for _, v := range vs {
+			var sub protoreflect.Message
+			if fd.IsList() {
+				i := idx[fd.Name()]
+				idx[fd.Name()]++
+				if i >= m.Get(fd).List().Len() {
+					return fmt.Errorf("%s: text does not match message", f.Name)
+				}
+				sub = m.Get(fd).List().Get(i).Message()
+			} else {
+				sub = m.Get(fd).Message()
+			}
+			if err := annotateUnknownText(sub, v.Message, as); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// annotateUnknownRaw matches the fields with numeric names in fs to the
+// encoded fields in b, in order.
+func annotateUnknownRaw(b []byte, fs []textField, as *[]textAnnotation) error {
+	for _, f := range fs {
+		if f.Number == 0 {
+			continue
+		}
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || num != f.Number {
+			return fmt.Errorf("unknown field %d: text does not match message", f.Number)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.Fixed32Type:
+			*as = append(*as, textAnnotation{f.Value.End, " # fixed32"})
+		case protowire.Fixed64Type:
+			*as = append(*as, textAnnotation{f.Value.End, " # fixed64"})
+		case protowire.StartGroupType:
+			g, n := protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				return fmt.Errorf("unknown field %d: %w", num, protowire.ParseError(n))
+			}
+			if err := annotateUnknownRaw(g, f.Value.Message, as); err != nil {
+				return err
+			}
+			b = b[n:]
+			continue
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+			return fmt.Errorf("unknown field %d: %w", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// unquoteText decodes a textproto string literal, which uses C-style escapes.
+func unquoteText(tok string) ([]byte, error) {
+	if len(tok) < 2 || tok[len(tok)-1] != tok[0] {
+		return nil, fmt.Errorf("unterminated string %s", tok)
+	}
+	var b []byte
+	for s := tok[1 : len(tok)-1]; len(s) != 0; {
+		if strings.HasPrefix(s, `\'`) || strings.HasPrefix(s, `\"`) {
+			b, s = append(b, s[1]), s[2:]
+			continue
+		}
+		r, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s: %w", tok, err)
+		}
+		if multibyte {
+			b = utf8.AppendRune(b, r)
+		} else {
+			b = append(b, byte(r))
+		}
+		s = tail
+	}
+	return b, nil
+}
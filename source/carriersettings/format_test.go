@@ -0,0 +1,226 @@
+package carriersettings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// withUnknown sets the unknown fields of m to b.
+func withUnknown[T proto.Message](m T, b ...[]byte) T {
+	var raw []byte
+	for _, b := range b {
+		raw = append(raw, b...)
+	}
+	m.ProtoReflect().SetUnknown(raw)
+	return m
+}
+
+func unknownVarint(num protowire.Number, v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+}
+
+func unknownFixed32(num protowire.Number, v uint32) []byte {
+	return protowire.AppendFixed32(protowire.AppendTag(nil, num, protowire.Fixed32Type), v)
+}
+
+func unknownFixed64(num protowire.Number, v uint64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, num, protowire.Fixed64Type), v)
+}
+
+func unknownBytes(num protowire.Number, v string) []byte {
+	return protowire.AppendString(protowire.AppendTag(nil, num, protowire.BytesType), v)
+}
+
+func unknownGroup(num protowire.Number, b ...[]byte) []byte {
+	g := protowire.AppendTag(nil, num, protowire.StartGroupType)
+	for _, b := range b {
+		g = append(g, b...)
+	}
+	return protowire.AppendTag(g, num, protowire.EndGroupType)
+}
+
+// testSettings returns settings with known and unknown fields of every kind
+// which are written the same way by prototext and MarshalText (i.e., no fixed32
+// or fixed64 unknown fields), with extra unknown fields added to the top-level
+// message.
+func testSettings(extra ...[]byte) *carrier_settings.CarrierSettings {
+	return withUnknown(&carrier_settings.CarrierSettings{
+		CanonicalName: proto.String("test_\"quoted\"\\\n\t\x00\x7fé"),
+		Version:       proto.Int64(-2),
+		Apns: withUnknown(&carrier_settings.CarrierApns{
+			Apn: []*carrier_settings.ApnItem{
+				withUnknown(&carrier_settings.ApnItem{
+					Name:     proto.String("first"),
+					Type:     []carrier_settings.ApnItem_ApnType{carrier_settings.ApnItem_DEFAULT, carrier_settings.ApnItem_MMS},
+					Authtype: proto.Int32(-1),
+				}, unknownVarint(100, 1), unknownBytes(101, "a\x00\xff\"b")),
+				{
+					Name: proto.String("second"),
+				},
+				withUnknown(&carrier_settings.ApnItem{
+					Name: proto.String("third"),
+				}, unknownVarint(100, 3), unknownVarint(100, 4)),
+			},
+		}, unknownBytes(50, "")),
+	}, append([][]byte{
+		unknownVarint(90, 0),
+		unknownVarint(91, uint64(1<<64-5)), // -5
+		unknownBytes(92, "\\'\"\a\b\f\n\r\t\v?\x01\u00e9\xe9"),
+		unknownGroup(93,
+			unknownVarint(1, 1),
+			unknownGroup(2,
+				unknownBytes(3, "nested"),
+				unknownGroup(4),
+			),
+			unknownVarint(1, 2),
+		),
+		unknownVarint(90, 1),
+	}, extra...)...)
+}
+
+func TestMarshalTextRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Msg  proto.Message
+	}{
+		{"Empty", &carrier_settings.CarrierSettings{}},
+		{"Settings", testSettings()},
+		{"Fixed", testSettings(
+			unknownFixed32(94, 0),
+			unknownFixed32(94, 5),
+			unknownFixed32(94, 1<<32-1),
+			unknownFixed64(95, 0),
+			unknownFixed64(95, 5),
+			unknownFixed64(95, 1<<32-1),
+			unknownFixed64(95, 1<<32),
+			unknownFixed64(95, 1<<64-1),
+			unknownGroup(96,
+				unknownFixed32(1, 5),
+				unknownGroup(2, unknownFixed64(3, 5)),
+			),
+		)},
+		{"Multi", &carrier_settings.MultiCarrierSettings{
+			Setting: []*carrier_settings.CarrierSettings{
+				testSettings(unknownFixed64(94, 5)),
+				{CanonicalName: proto.String("plain")},
+				testSettings(unknownFixed32(94, 5)),
+			},
+		}},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			b, err := MarshalText(prototext.MarshalOptions{Indent: "  "}, tc.Msg)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			m := tc.Msg.ProtoReflect().Type().New().Interface()
+			if err := UnmarshalProto("test.textpb", b, m); err != nil {
+				t.Fatalf("unmarshal: %v\n%s", err, b)
+			}
+			if !proto.Equal(tc.Msg, m) {
+				t.Errorf("round trip changed message\n%s", b)
+			}
+			if b1, err := MarshalText(prototext.MarshalOptions{Indent: "  "}, m); err != nil {
+				t.Errorf("marshal again: %v", err)
+			} else if string(b) != string(b1) {
+				t.Errorf("marshal again: text changed\n%s\n---\n%s", b, b1)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTextPrototext(t *testing.T) {
+	for _, tc := range []struct {
+		Name  string
+		Msg   proto.Message
+		Error string // if the wire type can't be determined
+	}{
+		{"Settings", testSettings(), ""},
+		{"Multi", &carrier_settings.MultiCarrierSettings{
+			Setting: []*carrier_settings.CarrierSettings{testSettings(), testSettings()},
+		}, ""},
+		{"Fixed64Large", testSettings(unknownFixed64(95, 1<<32)), ""},
+		{"Fixed32", testSettings(unknownFixed32(94, 5)), "could be fixed32 or fixed64"},
+		{"Fixed64", testSettings(unknownFixed64(95, 5)), "could be fixed32 or fixed64"},
+		{"Fixed64Group", testSettings(unknownGroup(96, unknownFixed64(1, 5))), "could be fixed32 or fixed64"},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			for _, o := range []prototext.MarshalOptions{
+				{EmitUnknown: true},
+				{EmitUnknown: true, Multiline: true},
+				{EmitUnknown: true, Indent: "\t"},
+			} {
+				b, err := o.Marshal(tc.Msg)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+				m := tc.Msg.ProtoReflect().Type().New().Interface()
+				err = UnmarshalProto("test.textpb", b, m)
+				if tc.Error != "" {
+					if err == nil || !strings.Contains(err.Error(), tc.Error) {
+						t.Errorf("unmarshal: expected error containing %q, got %v\n%s", tc.Error, err, b)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("unmarshal: %v\n%s", err, b)
+				}
+				if !proto.Equal(tc.Msg, m) {
+					t.Errorf("round trip changed message\n%s", b)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		Name string
+		Text string
+		Want proto.Message // nil if an error is expected
+	}{
+		{"ConcatenatedStrings", `canonical_name: "a" 'b' "\x63"` + "\n" + `99: "d" 'e'`,
+			withUnknown(&carrier_settings.CarrierSettings{CanonicalName: proto.String("abc")}, unknownBytes(99, "de"))},
+		{"Escapes", `canonical_name: "\101\x42\u0043\n\'\"" 99: '\000\377\\'`,
+			withUnknown(&carrier_settings.CarrierSettings{CanonicalName: proto.String("ABC\n'\"")}, unknownBytes(99, "\x00\xff\\"))},
+		{"NegativeVarint", `version: -1 99: -5 99: 18446744073709551611`,
+			withUnknown(&carrier_settings.CarrierSettings{Version: proto.Int64(-1)}, unknownVarint(99, 1<<64-5), unknownVarint(99, 1<<64-5))},
+		{"Fixed", "99: 0x5 # fixed64\n98: 0X5 # fixed32 value\n97: 0x100000000\n",
+			withUnknown(&carrier_settings.CarrierSettings{}, unknownFixed64(99, 5), unknownFixed32(98, 5), unknownFixed64(97, 1<<32))},
+		{"FixedSeparators", "99: 0x5; # fixed64\n98: 0x5, # fixed32\n",
+			withUnknown(&carrier_settings.CarrierSettings{}, unknownFixed64(99, 5), unknownFixed32(98, 5))},
+		{"NestedGroups", `apns { 99 { 1: 1 2 < 3: "x" > } apn { name: "a" 98: 2 } apn { 98: 3 } }`,
+			&carrier_settings.CarrierSettings{
+				Apns: withUnknown(&carrier_settings.CarrierApns{
+					Apn: []*carrier_settings.ApnItem{
+						withUnknown(&carrier_settings.ApnItem{Name: proto.String("a")}, unknownVarint(98, 2)),
+						withUnknown(&carrier_settings.ApnItem{}, unknownVarint(98, 3)),
+					},
+				}, unknownGroup(99, unknownVarint(1, 1), unknownGroup(2, unknownBytes(3, "x")))),
+			}},
+		{"FixedAmbiguous", "99: 0x5", nil},
+		{"FixedAmbiguousSameLine", "99: 0x5 98: 1 # fixed64", nil},
+		{"Fixed32TooLarge", "99: 0x100000000 # fixed32", nil},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			var m carrier_settings.CarrierSettings
+			err := UnmarshalProto("test.textpb", []byte(tc.Text), &m)
+			if tc.Want == nil {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !proto.Equal(tc.Want, &m) {
+				t.Errorf("incorrect result: %v", prototext.MarshalOptions{EmitUnknown: true}.Format(&m))
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package carriersettings
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	CarrierListFile = "carrier_list.pb" // CarrierList
+	Tier2File       = "others.pb"       // MultiCarrierSettings
+)
+
+// DB is the contents of a Pixel CarrierSettings directory (e.g.,
+// product/etc/CarrierSettings).
+type DB struct {
+	CarrierList *carrier_list.CarrierList
+
+	// Settings contains the tier 2 settings from others.pb, combined with the
+	// tier 1 settings from the individual files using the MergePolicy.
+	Settings map[string]*carrier_settings.CarrierSettings // [canonicalName]
+
+	// Source is the file the settings for each carrier came from. If settings
+	// were merged, it is the last one.
+	Source map[string]string // [canonicalName]
+
+	// APNSource is the file each APN in the settings came from. It is not
+	// updated if the settings are modified.
+	APNSource map[string][]string // [canonicalName][apnIndex]
+
+	// Overwrites contains the settings which were combined with other ones, in
+	// the order they were loaded.
+	Overwrites []Overwrite
+
+	// Errors contains the files which could not be loaded.
+	Errors []error
+
+	// Files contains the messages as they were loaded, by file name. The
+	// carrier list and settings are copies of them, so they aren't modified by
+	// merging or by changes to the DB.
+	Files map[string]proto.Message
+
+	merge    MergePolicy
+	files    map[string]snapshot                    // [name] as loaded or last marshaled
+	settings map[string]snapshot                    // [canonicalName] as loaded or last marshaled
+	tier2    *carrier_settings.MultiCarrierSettings // others.pb as loaded or last marshaled
+}
+
+// Overwrite is a carrier with settings in more than one file.
+type Overwrite struct {
+	CanonicalName string
+	Old           string // file name
+	New           string // file name
+	Policy        MergePolicy
+	Kept          bool // whether the old settings were kept (MergeVersion)
+}
+
+// LoadOptions configures Load.
+type LoadOptions struct {
+	// Merge determines how settings for a carrier in multiple files are
+	// combined.
+	Merge MergePolicy
+}
+
+// Load loads a DB from fsys with the default options.
+func Load(fsys fs.FS) (*DB, error) {
+	return LoadOptions{}.Load(fsys)
+}
+
+// Load loads the carrier list and settings from fsys. Tier 1 settings (the
+// individual files) are loaded in lexical order. Files may be in any format
+// supported by UnmarshalProto, and if there are multiple files for the carrier
+// list or others.pb, the first one in the order of the extensions is used. If
+// the carrier list can't be loaded, an error is returned. Otherwise, errors are
+// collected in DB.Errors.
+func (o LoadOptions) Load(fsys fs.FS) (*DB, error) {
+	db := &DB{
+		Settings:  map[string]*carrier_settings.CarrierSettings{},
+		Source:    map[string]string{},
+		APNSource: map[string][]string{},
+		Files:     map[string]proto.Message{},
+		merge:     o.Merge,
+		files:     map[string]snapshot{},
+		settings:  map[string]snapshot{},
+	}
+
+	listFile := findFile(fsys, CarrierListFile)
+	list, err := readProto[*carrier_list.CarrierList](fsys, listFile)
+	if err != nil {
+		return nil, err
+	}
+	db.Files[listFile] = list
+	db.CarrierList = proto.Clone(list).(*carrier_list.CarrierList)
+	db.files[CarrierListFile] = takeSnapshot(db.CarrierList)
+
+	tier2File := findFile(fsys, Tier2File)
+	if tier2, err := readProto[*carrier_settings.MultiCarrierSettings](fsys, tier2File); err != nil {
+		db.Errors = append(db.Errors, err)
+	} else {
+		db.Files[tier2File] = tier2
+		db.files[Tier2File] = takeSnapshot(tier2)
+		db.tier2 = tier2
+		for _, cs := range tier2.GetSetting() {
+			db.add(tier2File, proto.Clone(cs).(*carrier_settings.CarrierSettings))
+		}
+	}
+
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			db.Errors = append(db.Errors, err)
+			return nil
+		}
+		if d.IsDir() || !isProtoFile(name) || isFile(name, CarrierListFile) || isFile(name, Tier2File) {
+			return nil
+		}
+		cs, err := readProto[*carrier_settings.CarrierSettings](fsys, name)
+		if err != nil {
+			db.Errors = append(db.Errors, err)
+			return nil
+		}
+		db.Files[name] = cs
+		db.add(name, proto.Clone(cs).(*carrier_settings.CarrierSettings))
+		return nil
+	}); err != nil {
+		db.Errors = append(db.Errors, err)
+	}
+	return db, nil
+}
+
+func (db *DB) add(name string, cs *carrier_settings.CarrierSettings) {
+	if cs.CanonicalName == nil {
+		db.Errors = append(db.Errors, fmt.Errorf("read %T from %q: missing canonical name", cs, name))
+		return
+	}
+	canonicalName := cs.GetCanonicalName()
+	apnSource := make([]string, len(cs.GetApns().GetApn()))
+	for i := range apnSource {
+		apnSource[i] = name
+	}
+	if prev, ok := db.Settings[canonicalName]; ok {
+		o := Overwrite{
+			CanonicalName: canonicalName,
+			Old:           db.Source[canonicalName],
+			New:           name,
+			Policy:        db.merge,
+		}
+		switch db.merge {
+		case MergeFields:
+			apnSource = MergeSettings(prev, cs, db.APNSource[canonicalName], apnSource)
+			cs = prev
+		case MergeVersion:
+			o.Kept = prev.GetVersion() > cs.GetVersion()
+		}
+		db.Overwrites = append(db.Overwrites, o)
+		if o.Kept {
+			return
+		}
+	}
+	db.Settings[canonicalName] = cs
+	db.Source[canonicalName] = name
+	db.APNSource[canonicalName] = apnSource
+	db.settings[canonicalName] = takeSnapshot(cs)
+}
+
+// Err returns the load errors joined together.
+func (db *DB) Err() error {
+	return errors.Join(db.Errors...)
+}
+
+// CarrierMap returns the carrier list entries for each canonical name.
+func (db *DB) CarrierMap() map[string][]*carrier_list.CarrierMap {
+	m := map[string][]*carrier_list.CarrierMap{} // [canonicalName]
+	for _, c := range db.CarrierList.GetEntry() {
+		m[c.GetCanonicalName()] = append(m[c.GetCanonicalName()], c)
+	}
+	return m
+}
+
+func readProto[T proto.Message](fsys fs.FS, fn string) (T, error) {
+	var z T
+	msg := reflect.New(reflect.TypeOf(z).Elem()).Interface().(T)
+	buf, err := fs.ReadFile(fsys, fn)
+	if err != nil {
+		return z, fmt.Errorf("read %T from %q: %w", msg, fn, err)
+	}
+	if err := UnmarshalProto(fn, buf, msg); err != nil {
+		return z, fmt.Errorf("read %T from %q: %w", msg, fn, err)
+	}
+	return msg, nil
+}
+
+// findFile returns the first existing file with the same name as fn and an
+// extension supported by UnmarshalProto, or fn if there aren't any.
+func findFile(fsys fs.FS, fn string) string {
+	stem := strings.TrimSuffix(fn, path.Ext(fn))
+	for _, x := range formatExts {
+		if _, err := fs.Stat(fsys, stem+x.Ext); err == nil {
+			return stem + x.Ext
+		}
+	}
+	return fn
+}
+
+// isFile checks if name is fn with any extension supported by UnmarshalProto.
+func isFile(name, fn string) bool {
+	return isProtoFile(name) && strings.TrimSuffix(name, path.Ext(name)) == strings.TrimSuffix(fn, path.Ext(fn))
+}
+
+func isProtoFile(name string) bool {
+	_, ok := FormatFromExt(path.Ext(name))
+	return ok
+}
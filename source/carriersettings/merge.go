@@ -0,0 +1,131 @@
+package carriersettings
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/proto"
+)
+
+// MergePolicy determines how settings for the same carrier from multiple files
+// are combined. The tier 2 settings (others.pb) are loaded first, followed by
+// the tier 1 settings (the individual files) in lexical order.
+type MergePolicy int
+
+const (
+	MergeReplace MergePolicy = iota // the later settings replace the earlier ones
+	MergeFields                     // the later settings are merged field by field into the earlier ones (see MergeSettings)
+	MergeVersion                    // the settings with the highest version are kept (or the later ones if equal)
+)
+
+func (p MergePolicy) String() string {
+	switch p {
+	case MergeReplace:
+		return "replace"
+	case MergeFields:
+		return "fields"
+	case MergeVersion:
+		return "version"
+	default:
+		return ""
+	}
+}
+
+func (p MergePolicy) MarshalText() ([]byte, error) {
+	if s := p.String(); s != "" {
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("invalid merge policy %d", int(p))
+}
+
+func (p *MergePolicy) UnmarshalText(b []byte) error {
+	for _, x := range []MergePolicy{MergeReplace, MergeFields, MergeVersion} {
+		if x.String() == string(b) {
+			*p = x
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid merge policy %q", b)
+}
+
+// FileTier returns the tier of the settings from the specified file (2 for
+// others.pb, 1 otherwise).
+func FileTier(name string) int {
+	if isFile(name, Tier2File) {
+		return 2
+	}
+	return 1
+}
+
+// MergeSettings merges src into dst, returning the source (a or b) of each APN
+// in the result, given the sources of each APN in dst (a) and src (b).
+//
+// Singular fields which are set in src replace the ones in dst, and messages
+// are merged recursively. APNs with the same name and value are merged (with
+// the APN types replaced rather than appended), and configs and vendor configs
+// with the same key or name are replaced. Other list items from src are
+// appended.
+func MergeSettings(dst, src *carrier_settings.CarrierSettings, a, b []string) []string {
+	var (
+		srcApns    = src.GetApns().GetApn()
+		srcConfigs = src.GetConfigs().GetConfig()
+		srcVendor  = src.GetVendorConfigs().GetClient()
+	)
+	src = proto.Clone(src).(*carrier_settings.CarrierSettings)
+	if src.Apns != nil {
+		src.Apns.Apn = nil
+	}
+	if src.Configs != nil {
+		src.Configs.Config = nil
+	}
+	if src.VendorConfigs != nil {
+		src.VendorConfigs.Client = nil
+	}
+	proto.Merge(dst, src)
+
+	apnSource := slices.Clone(a)
+	for i, x := range srcApns {
+		s := b[i]
+		if dst.Apns == nil {
+			dst.Apns = &carrier_settings.CarrierApns{}
+		}
+		if j := slices.IndexFunc(dst.Apns.Apn, func(y *carrier_settings.ApnItem) bool {
+			return x.GetName() == y.GetName() && x.GetValue() == y.GetValue()
+		}); j != -1 {
+			if len(x.Type) != 0 {
+				dst.Apns.Apn[j].Type = nil // replace it instead of appending
+			}
+			proto.Merge(dst.Apns.Apn[j], x)
+			apnSource[j] = s
+		} else {
+			dst.Apns.Apn = append(dst.Apns.Apn, proto.Clone(x).(*carrier_settings.ApnItem))
+			apnSource = append(apnSource, s)
+		}
+	}
+	for _, x := range srcConfigs {
+		if dst.Configs == nil {
+			dst.Configs = &carrier_settings.CarrierConfig{}
+		}
+		if j := slices.IndexFunc(dst.Configs.Config, func(y *carrier_settings.CarrierConfig_Config) bool {
+			return x.GetKey() == y.GetKey()
+		}); j != -1 {
+			dst.Configs.Config[j] = proto.Clone(x).(*carrier_settings.CarrierConfig_Config)
+		} else {
+			dst.Configs.Config = append(dst.Configs.Config, proto.Clone(x).(*carrier_settings.CarrierConfig_Config))
+		}
+	}
+	for _, x := range srcVendor {
+		if dst.VendorConfigs == nil {
+			dst.VendorConfigs = &carrier_settings.VendorConfigs{}
+		}
+		if j := slices.IndexFunc(dst.VendorConfigs.Client, func(y *carrier_settings.VendorConfigClient) bool {
+			return x.GetName() == y.GetName()
+		}); j != -1 {
+			dst.VendorConfigs.Client[j] = proto.Clone(x).(*carrier_settings.VendorConfigClient)
+		} else {
+			dst.VendorConfigs.Client = append(dst.VendorConfigs.Client, proto.Clone(x).(*carrier_settings.VendorConfigClient))
+		}
+	}
+	return apnSource
+}
@@ -0,0 +1,114 @@
+package carriersettings
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+)
+
+// MatchKind is how a carrier_list rule was mapped to carrierId.
+type MatchKind int
+
+const (
+	MatchNone  MatchKind = iota // no match
+	MatchPLMN                   // only the mccmnc matched a single remaining carrier id
+	MatchExact                  // the mccmnc and mvno data matched an attribute exactly
+)
+
+func (k MatchKind) String() string {
+	switch k {
+	case MatchNone:
+		return "none"
+	case MatchPLMN:
+		return "plmn"
+	case MatchExact:
+		return "exact"
+	default:
+		return ""
+	}
+}
+
+func (k MatchKind) MarshalText() ([]byte, error) {
+	if s := k.String(); s != "" {
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("invalid match kind %d", int(k))
+}
+
+// MappingRule is a carrier_list rule and the carrier ids it was mapped to.
+type MappingRule struct {
+	Match      string    `json:"match"` // see FormatCarrierMatch
+	Kind       MatchKind `json:"kind"`
+	CarrierIDs []int32   `json:"carrier_ids"` // every match, even if only the first one is used
+}
+
+// MappingRow is the carrierId mapping for a carrier.
+type MappingRow struct {
+	CanonicalName string        `json:"canonical_name"`
+	Kind          MatchKind     `json:"kind"` // best kind of the rules
+	CarrierIDs    []int32       `json:"carrier_ids"`
+	Rules         []MappingRule `json:"rules"`
+	Collisions    []string      `json:"collisions"` // other canonical names mapped to the same carrier ids
+	APNs          int           `json:"apns"`
+}
+
+// FormatCarrierMatch formats a carrier_list rule as the mccmnc followed by the
+// mvno type and data, if any (e.g., "302720 gid1=ab").
+func FormatCarrierMatch(c *carrier_list.CarrierId) string {
+	m := c.GetMccMnc()
+	switch v := c.MvnoData.(type) {
+	case *carrier_list.CarrierId_Spn:
+		m += " spn=" + v.Spn
+	case *carrier_list.CarrierId_Imsi:
+		m += " imsi=" + v.Imsi
+	case *carrier_list.CarrierId_Gid1:
+		m += " gid1=" + v.Gid1
+	}
+	return m
+}
+
+// WriteMappingJSON writes rows as an indented JSON array.
+func WriteMappingJSON(w io.Writer, rows []MappingRow) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(rows)
+}
+
+// WriteMappingCSV writes rows as CSV with a header. Each rule is written on a
+// separate line with the carrier's columns repeated, since the match may
+// contain any character. Carriers without rules have empty rule columns. Lists
+// of carrier ids and canonical names are separated by semicolons.
+func WriteMappingCSV(w io.Writer, rows []MappingRow) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"canonical_name", "kind", "carrier_ids", "collisions", "apns", "rule_match", "rule_kind", "rule_carrier_ids"})
+	for _, r := range rows {
+		row := []string{
+			r.CanonicalName,
+			r.Kind.String(),
+			formatIDs(r.CarrierIDs, ";"),
+			strings.Join(r.Collisions, ";"),
+			strconv.Itoa(r.APNs),
+		}
+		if len(r.Rules) == 0 {
+			c.Write(append(row, "", "", ""))
+		}
+		for _, x := range r.Rules {
+			c.Write(append(row[:len(row):len(row)], x.Match, x.Kind.String(), formatIDs(x.CarrierIDs, ";")))
+		}
+	}
+	c.Flush()
+	return c.Error()
+}
+
+func formatIDs(ids []int32, sep string) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(s, sep)
+}
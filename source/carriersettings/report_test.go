@@ -0,0 +1,37 @@
+package carriersettings
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMappingCSV(t *testing.T) {
+	var b strings.Builder
+	if err := WriteMappingCSV(&b, []MappingRow{
+		{
+			CanonicalName: "a",
+			Kind:          MatchExact,
+			CarrierIDs:    []int32{1, 2},
+			Rules: []MappingRule{
+				{Match: "302220 spn=x:y;z", Kind: MatchExact, CarrierIDs: []int32{1}},
+				{Match: "302221", Kind: MatchPLMN, CarrierIDs: []int32{2, 3}},
+			},
+			Collisions: []string{"b"},
+			APNs:       3,
+		},
+		{
+			CanonicalName: "b",
+			Kind:          MatchNone,
+		},
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want, got := strings.Join([]string{
+		"canonical_name,kind,carrier_ids,collisions,apns,rule_match,rule_kind,rule_carrier_ids",
+		"a,exact,1;2,b,3,302220 spn=x:y;z,exact,1",
+		"a,exact,1;2,b,3,302221,plmn,2;3",
+		"b,none,,,0,,,",
+	}, "\n")+"\n", b.String(); got != want {
+		t.Errorf("incorrect csv:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+}
@@ -0,0 +1,179 @@
+package carriersettings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// VendorConfigDecoder decodes the value of a VendorConfigClient. The result
+// should be a proto.Message or something which can be encoded as JSON.
+type VendorConfigDecoder func(b []byte) (any, error)
+
+var vendorConfigDecoders sync.Map // [name]VendorConfigDecoder
+
+// RegisterVendorConfigDecoder sets the decoder for the VendorConfigClient with
+// the specified name, replacing any existing one.
+//
+// No decoders are registered by default. The VendorConfigData message used by
+// some clients is defined in vendorconfigdata.proto, which isn't published with
+// carrier_settings.proto, so it isn't vendored, and those clients are decoded
+// without a schema.
+func RegisterVendorConfigDecoder(name string, fn VendorConfigDecoder) {
+	vendorConfigDecoders.Store(name, fn)
+}
+
+// VendorConfigProtoDecoder returns a decoder which unmarshals the value into a
+// new message of the same type as m.
+func VendorConfigProtoDecoder(m proto.Message) VendorConfigDecoder {
+	t := m.ProtoReflect().Type()
+	return func(b []byte) (any, error) {
+		v := t.New().Interface()
+		if err := proto.Unmarshal(b, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// DecodeVendorConfigJSON decodes a JSON value.
+func DecodeVendorConfigJSON(b []byte) (any, error) {
+	var v any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("unexpected data after json value")
+	}
+	return v, nil
+}
+
+// DecodeVendorConfig decodes the value of c using the registered decoder for
+// its name. If there isn't one, it is decoded as JSON if it is a JSON object or
+// array, and as a protobuf message without a schema (see DecodeWire)
+// otherwise. If it is neither, the raw bytes are returned with an error.
+func DecodeVendorConfig(c *carrier_settings.VendorConfigClient) (any, error) {
+	b := c.GetValue()
+	if fn, ok := vendorConfigDecoders.Load(c.GetName()); ok {
+		v, err := fn.(VendorConfigDecoder)(b)
+		if err != nil {
+			return nil, fmt.Errorf("decode vendor config %q: %w", c.GetName(), err)
+		}
+		return v, nil
+	}
+	if t := bytes.TrimSpace(b); len(t) != 0 && (t[0] == '{' || t[0] == '[') {
+		if v, err := DecodeVendorConfigJSON(t); err == nil {
+			return v, nil
+		}
+	}
+	if v, err := DecodeWire(b); err == nil {
+		return v, nil
+	}
+	return b, fmt.Errorf("decode vendor config %q: unknown format", c.GetName())
+}
+
+// MarshalVendorConfigJSON encodes a value returned by DecodeVendorConfig as
+// indented JSON.
+func MarshalVendorConfigJSON(v any) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(m)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// WireField is a protobuf field decoded without a schema.
+type WireField struct {
+	Number protowire.Number `json:"number"`
+	Type   string           `json:"type"` // varint, fixed32, fixed64, bytes, group
+
+	// Value is an uint64 (varint/fixed64), uint32 (fixed32), []WireField
+	// (group, or bytes which are a valid message), string (bytes which are
+	// printable utf-8), or []byte.
+	Value any `json:"value"`
+}
+
+// DecodeWire decodes a protobuf message without a schema, like protoc
+// --decode_raw. Since the wire format is ambiguous, length-delimited values
+// are treated as strings if they are printable, as messages if they can be
+// parsed as one, and as bytes otherwise.
+func DecodeWire(b []byte) ([]WireField, error) {
+	var fs []WireField
+	for len(b) != 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := WireField{Number: num}
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			f.Type, f.Value, b = "varint", v, b[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			f.Type, f.Value, b = "fixed32", v, b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			f.Type, f.Value, b = "fixed64", v, b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			f.Type, b = "bytes", b[n:]
+			if isPrintable(v) {
+				f.Value = string(v)
+			} else if m, err := DecodeWire(v); err == nil && len(m) != 0 {
+				f.Value = m
+			} else {
+				f.Value = v
+			}
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			m, err := DecodeWire(v)
+			if err != nil {
+				return nil, fmt.Errorf("field %d: %w", num, err)
+			}
+			f.Type, f.Value, b = "group", m, b[n:]
+		default:
+			return nil, fmt.Errorf("field %d: unsupported wire type %d", num, typ)
+		}
+		fs = append(fs, f)
+	}
+	return fs, nil
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package carriersettings
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_list"
+	"github.com/pgaskin/apn-extract-utils/aosp/carrier_settings"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// snapshot is used to check whether a message has changed since it was loaded
+// or last marshaled.
+type snapshot struct {
+	version int64
+	hash    [sha256.Size]byte // of the deterministic encoding without the version
+}
+
+func takeSnapshot(m proto.Message) snapshot {
+	m = proto.Clone(m)
+	r := m.ProtoReflect()
+	fd := r.Descriptor().Fields().ByName("version")
+	v := r.Get(fd).Int()
+	r.Clear(fd)
+	buf, _ := proto.MarshalOptions{Deterministic: true, AllowPartial: true}.Marshal(m)
+	return snapshot{v, sha256.Sum256(buf)}
+}
+
+// bumpVersion sets the version of m to one more than the previous one if it
+// has changed since old was taken, and returns the new snapshot. If old is
+// missing, the version is set to 1 if it is not already positive.
+func bumpVersion(m proto.Message, old snapshot, ok bool) snapshot {
+	r := m.ProtoReflect()
+	fd := r.Descriptor().Fields().ByName("version")
+	cur := takeSnapshot(m)
+	switch {
+	case !ok:
+		cur.version = max(cur.version, 1)
+	case cur.hash != old.hash:
+		cur.version = max(cur.version, old.version+1)
+	default:
+		cur.version = max(cur.version, old.version)
+	}
+	r.Set(fd, protoreflect.ValueOfInt64(cur.version))
+	return cur
+}
+
+// Marshal encodes db as a CarrierSettings directory, returning the contents of
+// each file. Carriers with settings from others.pb are written to it, and the
+// other ones are written to <canonical_name>.pb. Tier 1 settings of carriers
+// which were overwritten while loading are not preserved.
+//
+// If others.pb was loaded, it is used as the starting point, so its unknown
+// fields and the order of the settings are kept, unchanged settings are
+// written as they were loaded or last marshaled (including duplicates), and the
+// settings of carriers which were overwritten by a tier 1 file are kept. New
+// carriers are added to the end.
+//
+// The versions of the carrier list, others.pb, and the settings are
+// incremented (in db) if they have changed since they were loaded or last
+// marshaled. The output is deterministic.
+//
+// An error is returned if a message isn't valid (e.g., missing required
+// fields), if a carrier's canonical name doesn't match its key, or if a
+// carrier doesn't have any carrier list entries.
+func (db *DB) Marshal() (map[string][]byte, error) {
+	if db.files == nil {
+		db.files = map[string]snapshot{}
+	}
+	if db.settings == nil {
+		db.settings = map[string]snapshot{}
+	}
+	if db.CarrierList == nil {
+		db.CarrierList = &carrier_list.CarrierList{}
+	}
+
+	var (
+		errs   []error
+		files  = map[string]proto.Message{}
+		tier2  = map[string]*carrier_settings.CarrierSettings{} // [canonicalName]
+		dirty  = map[string]bool{}                              // [canonicalName]
+		listed = db.CarrierMap()
+	)
+	for _, canonicalName := range slices.Sorted(maps.Keys(db.Settings)) {
+		cs := db.Settings[canonicalName]
+		if cs.CanonicalName == nil {
+			cs.CanonicalName = proto.String(canonicalName)
+		} else if v := cs.GetCanonicalName(); v != canonicalName {
+			errs = append(errs, fmt.Errorf("carrier %q: canonical name %q does not match", canonicalName, v))
+			continue
+		}
+		if len(listed[canonicalName]) == 0 {
+			errs = append(errs, fmt.Errorf("carrier %q: not in carrier list", canonicalName))
+			continue
+		}
+		old, ok := db.settings[canonicalName]
+		cur := bumpVersion(cs, old, ok)
+		db.settings[canonicalName] = cur
+
+		if isFile(db.Source[canonicalName], Tier2File) {
+			tier2[canonicalName] = cs
+			dirty[canonicalName] = !ok || cur != old
+		} else {
+			files[canonicalName+".pb"] = cs
+		}
+	}
+	if len(errs) != 0 {
+		return nil, errors.Join(errs...)
+	}
+	files[CarrierListFile] = db.CarrierList
+	if m := db.tier2Settings(tier2, dirty); len(m.Setting) != 0 {
+		files[Tier2File] = m
+	}
+
+	// do the top-level files last so the versions of the settings are updated
+	out := map[string][]byte{}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		m := files[name]
+		if name == CarrierListFile || name == Tier2File {
+			old, ok := db.files[name]
+			db.files[name] = bumpVersion(m, old, ok)
+		}
+		buf, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("write %T to %q: %w", m, name, err))
+			continue
+		}
+		out[name] = buf
+	}
+	if m, ok := files[Tier2File]; ok {
+		db.tier2 = proto.Clone(m).(*carrier_settings.MultiCarrierSettings)
+	}
+	return out, errors.Join(errs...)
+}
+
+// tier2Settings builds others.pb from the loaded one and the settings to write
+// to it (see Marshal).
+func (db *DB) tier2Settings(settings map[string]*carrier_settings.CarrierSettings, dirty map[string]bool) *carrier_settings.MultiCarrierSettings {
+	m := &carrier_settings.MultiCarrierSettings{}
+	if db.tier2 != nil {
+		m = proto.Clone(db.tier2).(*carrier_settings.MultiCarrierSettings)
+		m.Setting = nil
+	}
+	written := map[string]bool{}
+	for _, cs := range db.tier2.GetSetting() {
+		canonicalName := cs.GetCanonicalName()
+		switch x, ok := settings[canonicalName]; {
+		case ok && !dirty[canonicalName]:
+			m.Setting = append(m.Setting, cs)
+		case ok:
+			if !written[canonicalName] {
+				m.Setting = append(m.Setting, x) // replaces the duplicates
+			}
+		case db.Settings[canonicalName] != nil:
+			m.Setting = append(m.Setting, cs) // overwritten by a tier 1 file
+		default:
+			continue // removed
+		}
+		written[canonicalName] = true
+	}
+	for _, canonicalName := range slices.Sorted(maps.Keys(settings)) {
+		if !written[canonicalName] {
+			m.Setting = append(m.Setting, settings[canonicalName])
+		}
+	}
+	return m
+}
+
+// Write marshals db and writes it to dir, creating it if needed. Existing files
+// which aren't part of db are not removed.
+func (db *DB) Write(dir string) error {
+	files, err := db.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), files[name], 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}